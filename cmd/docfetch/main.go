@@ -1,20 +1,70 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/AlphaTechini/doc-fetch/pkg/fetcher"
 )
 
+// stringList collects a flag that may be given more than once
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func main() {
 	url := flag.String("url", "", "Base URL to fetch documentation from")
 	output := flag.String("output", "docs.md", "Output file path")
+	outputDir := flag.String("output-dir", "", "Write one Markdown file per page under this directory, plus an index.md")
+	format := flag.String("format", "markdown", "Output format: \"markdown\", \"jsonl\" (one JSON record per page) or \"chunks\" (one JSON record per chunk)")
+	grouped := flag.Bool("grouped", false, "Group pages by category behind a table of contents (Markdown output)")
+	navOrder := flag.Bool("nav-order", false, "Order pages and nest their headings as the site's sidebar does (Markdown output)")
+	reproducible := flag.Bool("reproducible", false, "Write pages in a stable order without timestamps so reruns over an unchanged site produce identical output")
+	chunkTokens := flag.Int("chunk-tokens", 512, "Maximum tokens per chunk with --format chunks")
+	chunkOverlap := flag.Int("chunk-overlap", 64, "Tokens a continued section repeats from the previous chunk (0 disables)")
+	tokenEstimator := flag.String("token-estimator", fetcher.DefaultTokenEstimator, "Token estimator for chunking: \"heuristic\", \"chars\" or \"words\"")
 	depth := flag.Int("depth", 2, "Maximum crawl depth")
 	concurrent := flag.Int("concurrent", 3, "Concurrent fetchers")
 	userAgent := flag.String("user-agent", "DocFetch/1.0", "Custom user agent")
-	llmTxt := flag.Bool("llm-txt", false, "Generate llm.txt index file")
+	llmTxt := flag.Bool("llm-txt", false, "Generate llms.txt and llms-full.txt (or llm.txt with --llm-txt-format legacy)")
+	llmTxtFormat := flag.String("llm-txt-format", fetcher.LLMTxtSpec, "LLM index format: \"spec\" (llmstxt.org llms.txt and llms-full.txt) or \"legacy\" (llm.txt)")
+	llmTxtSections := flag.String("llm-txt-sections", fetcher.LLMTxtSectionsAuto, "llms.txt sections: \"auto\", \"nav\" (site sidebar) or \"type\" (page type)")
+	maxPages := flag.Int("max-pages", 0, "Maximum pages to fetch (0 = unlimited)")
+	timeout := flag.Duration("timeout", 10*time.Minute, "Overall crawl timeout")
+	ignoreRobots := flag.Bool("ignore-robots", false, "Ignore robots.txt (only for sites you own)")
+	useSitemap := flag.Bool("sitemap", false, "Seed the crawl from the site's sitemap.xml")
+	sitemapURL := flag.String("sitemap-url", "", "Explicit sitemap or sitemap index URL (implies --sitemap)")
+	sitemapLastMod := flag.Bool("sitemap-lastmod", false, "Fetch sitemap pages newest <lastmod> first")
+	rateLimit := flag.Float64("rate", 5, "Maximum requests per second per host")
+	rateBurst := flag.Int("burst", 5, "Requests allowed in a burst per host")
+	adaptiveRate := flag.Bool("adaptive-rate", false, "Back off on 429/503 responses and honor Retry-After")
+	retries := flag.Int("retries", 3, "Retries per page for transient failures (0 disables)")
+	retryBudget := flag.Int("retry-budget", 100, "Total retries allowed across the whole run")
+	retryDelay := flag.Duration("retry-delay", 500*time.Millisecond, "Backoff before the first retry, doubled each attempt")
+	retryMaxDelay := flag.Duration("retry-max-delay", 30*time.Second, "Longest backoff between retries")
+	var includes, excludes stringList
+	flag.Var(&includes, "include", "Only follow URLs matching this glob or re:regex (repeatable)")
+	flag.Var(&excludes, "exclude", "Never follow URLs matching this glob or re:regex (repeatable)")
+	prefix := flag.String("prefix", "", "Path prefix to stay under (default: derived from --url)")
+	noPrefixLock := flag.Bool("no-prefix-lock", false, "Follow links anywhere on the host")
+	dryRun := flag.Bool("dry-run", false, "Crawl and report which URLs are in scope without writing output")
+	siteConfig := flag.String("config", "", "JSON file of per-site content, remove and title selectors")
+	complexTables := flag.String("complex-tables", "html", "Render tables with colspan/rowspan as \"html\" or \"list\"")
+	report := flag.String("report", "", "Write a JSON report of how each page's content was extracted")
+	cacheDir := flag.String("cache-dir", "", "Keep raw responses in this directory and answer repeated requests from it")
+	offline := flag.Bool("offline", false, "Serve every request from --cache-dir and never touch the network")
+	state := flag.String("state", "", "Incremental state file (.json): only pages changed since the last run are downloaded and extracted again")
 
 	flag.Parse()
 
@@ -22,31 +72,113 @@ func main() {
 		log.Fatal("Error: URL is required\nUsage: doc-fetch --url <base-url> --output <file-path>")
 	}
 
+	// JSONL formats get a matching file name unless --output was given
+	outputSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "output" {
+			outputSet = true
+		}
+	})
+	if (*format == fetcher.FormatJSONL || *format == fetcher.FormatChunks) && !outputSet {
+		*output = "docs.jsonl"
+	}
+
+	estimator, err := fetcher.LookupTokenEstimator(*tokenEstimator)
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+
+	// Config treats zero as "use the default", so --retries 0 and --chunk-overlap 0 map to a negative value
+	if *retries == 0 {
+		*retries = -1
+	}
+	if *chunkOverlap == 0 {
+		*chunkOverlap = -1
+	}
+
 	// Validate configuration for security
 	config := fetcher.Config{
-		BaseURL:         *url,
-		OutputPath:      *output,
-		MaxDepth:        *depth,
-		Workers:         *concurrent,
-		UserAgent:       *userAgent,
-		GenerateLLMTxt:  *llmTxt,
+		BaseURL:             *url,
+		OutputPath:          *output,
+		OutputDir:           *outputDir,
+		Format:              *format,
+		Grouped:             *grouped,
+		NavOrder:            *navOrder,
+		Reproducible:        *reproducible,
+		ChunkTokens:         *chunkTokens,
+		ChunkOverlap:        *chunkOverlap,
+		TokenEstimator:      estimator,
+		MaxDepth:            *depth,
+		Workers:             *concurrent,
+		UserAgent:           *userAgent,
+		GenerateLLMTxt:      *llmTxt,
+		LLMTxtFormat:        *llmTxtFormat,
+		LLMTxtSections:      *llmTxtSections,
+		MaxPages:            *maxPages,
+		Timeout:             *timeout,
+		IgnoreRobots:        *ignoreRobots,
+		UseSitemap:          *useSitemap,
+		SitemapURL:          *sitemapURL,
+		SitemapLastModOrder: *sitemapLastMod,
+		RateLimit:           *rateLimit,
+		RateBurst:           *rateBurst,
+		AdaptiveRateLimit:   *adaptiveRate,
+		MaxRetries:          *retries,
+		RetryBudget:         *retryBudget,
+		RetryBaseDelay:      *retryDelay,
+		RetryMaxDelay:       *retryMaxDelay,
+		IncludePatterns:     includes,
+		ExcludePatterns:     excludes,
+		ScopePrefix:         *prefix,
+		NoPrefixLock:        *noPrefixLock,
+		DryRun:              *dryRun,
+		TablePolicy:         *complexTables,
+		SiteConfigPath:      *siteConfig,
+		ReportPath:          *report,
+		StatePath:           *state,
+		CacheDir:            *cacheDir,
+		Offline:             *offline,
 	}
 
 	if err := fetcher.ValidateConfig(&config); err != nil {
 		log.Fatalf("Configuration error: %v", err)
 	}
 
+	// Ctrl+C stops the crawl gracefully and keeps the pages fetched so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	// Use optimized high-performance fetcher
-	err := fetcher.RunOptimized(config)
+	stats, err := fetcher.RunOptimizedContext(ctx, config)
 	if err != nil {
 		log.Fatalf("Failed to fetch documentation: %v", err)
 	}
+	if stats.StopReason != fetcher.StopExhausted {
+		log.Printf("Crawl stopped early (%s); output may be incomplete", stats.StopReason)
+	}
+	if *dryRun {
+		return
+	}
+
+	if *outputDir != "" {
+		log.Printf("Documentation successfully saved to %s (index: %s)", *outputDir, filepath.Join(*outputDir, "index.md"))
+	} else {
+		log.Printf("Documentation successfully saved to %s", *output)
+	}
+	if !*llmTxt {
+		return
+	}
 
-	log.Printf("Documentation successfully saved to %s", *output)
-	if *llmTxt {
+	switch {
+	case config.LLMTxtFormat == fetcher.LLMTxtSpec:
+		indexPath, fullPath := fetcher.LLMsTxtPaths(config)
+		log.Printf("llms.txt generated: %s (full text: %s)", indexPath, fullPath)
+	case *outputDir != "":
+		log.Printf("LLM.txt index generated: %s", filepath.Join(*outputDir, "llm.txt"))
+	default:
 		llmTxtPath := *output
-		if strings.HasSuffix(*output, ".md") {
-			llmTxtPath = strings.TrimSuffix(*output, ".md") + ".llm.txt"
+		if strings.HasSuffix(*output, ".md") || strings.HasSuffix(*output, ".jsonl") {
+			llmTxtPath = strings.TrimSuffix(strings.TrimSuffix(*output, ".md"), ".jsonl") + ".llm.txt"
 		} else {
 			llmTxtPath = *output + ".llm.txt"
 		}
@@ -4,8 +4,7 @@ go 1.21
 
 require (
 	github.com/PuerkitoBio/goquery v1.8.1
+	github.com/andybalholm/cascadia v1.3.1
 	github.com/yuin/goldmark v1.6.0
 	golang.org/x/net v0.17.0
 )
-
-require github.com/andybalholm/cascadia v1.3.1 // indirect
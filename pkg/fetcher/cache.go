@@ -0,0 +1,223 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// errNotCached is returned offline for requests the cache cannot answer
+var errNotCached = errors.New("not in the response cache (offline)")
+
+// uncachedHeaders are never written to disk: credentials, cookies and
+// hop-by-hop headers that only meant something on the original connection
+var uncachedHeaders = []string{
+	"Set-Cookie", "Set-Cookie2", "Authorization", "Proxy-Authorization",
+	"WWW-Authenticate", "Proxy-Authenticate", "Connection", "Keep-Alive",
+	"Transfer-Encoding", "Upgrade", "TE", "Trailer",
+}
+
+// responseCache is an HTTP transport that keeps raw responses on disk and
+// answers repeated GETs from there. Response headers are stored under a hash
+// of the request URL and bodies under a hash of their bytes, so pages that
+// serve the same content share one file.
+type responseCache struct {
+	dir     string
+	offline bool // Answer only from the cache, never from the network
+	next    http.RoundTripper
+	hits    int32
+	misses  int32
+}
+
+// cachedResponse is a response's status and headers as stored on disk.
+// Entries never expire: Cache-Control and Expires are ignored, so a cache
+// directory replays the site as it was until the directory is deleted.
+type cachedResponse struct {
+	URL       string      `json:"url"`
+	Status    int         `json:"status"`
+	Header    http.Header `json:"header"`
+	Body      string      `json:"body"` // "sha256:" and the hex digest of the body file
+	FetchedAt time.Time   `json:"fetched_at"`
+}
+
+// newResponseCache opens or creates a cache directory in front of next
+func newResponseCache(dir string, offline bool, next http.RoundTripper) (*responseCache, error) {
+	if offline {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("offline mode needs an existing cache directory: %w", err)
+		}
+	}
+	for _, sub := range []string{"responses", "bodies"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, err
+		}
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &responseCache{dir: dir, offline: offline, next: next}, nil
+}
+
+// cacheableStatus reports whether a response is worth replaying: pages,
+// redirects and missing files, but not 304s or transient failures
+func cacheableStatus(status int) bool {
+	switch {
+	case status == http.StatusOK, status == http.StatusNotFound, status == http.StatusGone:
+		return true
+	case status >= 300 && status < 400 && status != http.StatusNotModified:
+		return true
+	}
+	return false
+}
+
+// RoundTrip answers GETs from the cache, fetching and storing the ones it
+// does not have unless the cache is offline
+func (c *responseCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		if c.offline {
+			return nil, errNotCached
+		}
+		return c.next.RoundTrip(req)
+	}
+
+	if resp, ok := c.load(req); ok {
+		atomic.AddInt32(&c.hits, 1)
+		return resp, nil
+	}
+	atomic.AddInt32(&c.misses, 1)
+	if c.offline {
+		return nil, errNotCached
+	}
+
+	resp, err := c.next.RoundTrip(req)
+	if err != nil || !cacheableStatus(resp.StatusCode) {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if len(body) > maxPageSize {
+		// Too large to keep; pass the rest of the body through as it arrives
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	if err := c.store(req, resp, body); err != nil {
+		log.Printf("⚠️  Warning: Could not cache %s: %v", req.URL, err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// has reports whether the cache can answer a request
+func (c *responseCache) has(req *http.Request) bool {
+	if c == nil || req.Method != http.MethodGet {
+		return false
+	}
+	_, err := os.Stat(c.responsePath(req.URL.String()))
+	return err == nil
+}
+
+// counts returns the requests answered from the cache and the ones that were not
+func (c *responseCache) counts() (hits, misses int) {
+	return int(atomic.LoadInt32(&c.hits)), int(atomic.LoadInt32(&c.misses))
+}
+
+// load replays a stored response; a missing or damaged entry is a miss
+func (c *responseCache) load(req *http.Request) (*http.Response, bool) {
+	data, err := os.ReadFile(c.responsePath(req.URL.String()))
+	if err != nil {
+		return nil, false
+	}
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, false
+	}
+	body, err := os.ReadFile(c.bodyPath(cached.Body))
+	if err != nil || contentHash(string(body)) != cached.Body {
+		return nil, false
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", cached.Status, http.StatusText(cached.Status)),
+		StatusCode:    cached.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cached.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, true
+}
+
+// store writes the body, unless an identical one is already stored, then the response
+func (c *responseCache) store(req *http.Request, resp *http.Response, body []byte) error {
+	cached := cachedResponse{
+		URL:       req.URL.String(),
+		Status:    resp.StatusCode,
+		Header:    cacheableHeader(resp.Header),
+		Body:      contentHash(string(body)),
+		FetchedAt: time.Now().UTC(),
+	}
+
+	bodyPath := c.bodyPath(cached.Body)
+	if _, err := os.Stat(bodyPath); err != nil {
+		if err := writeFileAtomic(bodyPath, body); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(cached, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.responsePath(cached.URL), append(data, '\n'))
+}
+
+// cacheableHeader copies a response header without the headers that must not be stored
+func cacheableHeader(header http.Header) http.Header {
+	kept := header.Clone()
+	// Connection may name more hop-by-hop headers
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			kept.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range uncachedHeaders {
+		kept.Del(name)
+	}
+	return kept
+}
+
+// responsePath is where the response to a URL is stored
+func (c *responseCache) responsePath(rawURL string) string {
+	return c.objectPath("responses", contentHash(rawURL)) + ".json"
+}
+
+// bodyPath is where a body with the given "sha256:" hash is stored
+func (c *responseCache) bodyPath(hash string) string {
+	return c.objectPath("bodies", hash)
+}
+
+// objectPath spreads files over 256 subdirectories by the first byte of their hash
+func (c *responseCache) objectPath(kind, hash string) string {
+	digest := strings.TrimPrefix(hash, "sha256:")
+	return filepath.Join(c.dir, kind, digest[:2], digest)
+}
@@ -0,0 +1,69 @@
+package fetcher
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestCacheableHeader(t *testing.T) {
+	header := http.Header{
+		"Content-Type":        {"text/html; charset=utf-8"},
+		"Etag":                {`"v1"`},
+		"Set-Cookie":          {"session=secret"},
+		"Set-Cookie2":         {"old=secret"},
+		"Authorization":       {"Bearer secret"},
+		"Www-Authenticate":    {"Basic"},
+		"Proxy-Authenticate":  {"Basic"},
+		"Proxy-Authorization": {"Basic secret"},
+		"Connection":          {"keep-alive, X-Hop"},
+		"Keep-Alive":          {"timeout=5"},
+		"Transfer-Encoding":   {"chunked"},
+		"Upgrade":             {"h2c"},
+		"Te":                  {"trailers"},
+		"Trailer":             {"Expires"},
+		"X-Hop":               {"named by Connection"},
+	}
+
+	kept := cacheableHeader(header)
+	for _, name := range []string{"Content-Type", "ETag"} {
+		if kept.Get(name) == "" {
+			t.Errorf("%s was dropped", name)
+		}
+	}
+	for _, name := range append(uncachedHeaders, "X-Hop") {
+		if values := kept.Values(name); len(values) > 0 {
+			t.Errorf("%s was kept: %v", name, values)
+		}
+	}
+	if header.Get("Set-Cookie") == "" {
+		t.Error("the response's own header was modified")
+	}
+}
+
+func TestResponseCacheStoreDropsCredentials(t *testing.T) {
+	cache, err := newResponseCache(t.TempDir(), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &http.Request{Method: http.MethodGet, URL: &url.URL{Scheme: "https", Host: "docs.example.com", Path: "/"}}
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{
+		"Content-Type": {"text/html"},
+		"Set-Cookie":   {"session=secret"},
+	}}
+	if err := cache.store(req, resp, []byte("<html></html>")); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, ok := cache.load(req)
+	if !ok {
+		t.Fatal("stored response was not found")
+	}
+	if replayed.Header.Get("Set-Cookie") != "" {
+		t.Error("Set-Cookie was replayed from the cache")
+	}
+	if replayed.Header.Get("Content-Type") != "text/html" {
+		t.Errorf("Content-Type = %q, want text/html", replayed.Header.Get("Content-Type"))
+	}
+}
@@ -0,0 +1,155 @@
+package fetcher
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// trackingParams are query parameters that never change page content
+var trackingParams = map[string]bool{
+	"gclid":   true,
+	"dclid":   true,
+	"fbclid":  true,
+	"msclkid": true,
+	"yclid":   true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+	"_gl":     true,
+	"ref_src": true,
+}
+
+// indexFiles are directory index pages that are the same page as their directory
+var indexFiles = map[string]bool{
+	"index.html":   true,
+	"index.htm":    true,
+	"index.php":    true,
+	"default.htm":  true,
+	"default.html": true,
+	"default.aspx": true,
+}
+
+// cleanURL prepares a discovered URL for fetching: it drops the fragment and
+// tracking parameters, lowercases the scheme and host and removes default ports
+func cleanURL(u *url.URL) *url.URL {
+	clean := *u
+	clean.Fragment = ""
+	clean.RawFragment = ""
+	clean.Scheme = strings.ToLower(clean.Scheme)
+	clean.Host = strings.ToLower(clean.Host)
+
+	if port := clean.Port(); (port == "80" && clean.Scheme == "http") || (port == "443" && clean.Scheme == "https") {
+		clean.Host = strings.TrimSuffix(clean.Host, ":"+port)
+	}
+
+	if clean.RawQuery != "" {
+		query := clean.Query()
+		for key := range query {
+			if trackingParams[strings.ToLower(key)] || strings.HasPrefix(strings.ToLower(key), "utm_") {
+				query.Del(key)
+			}
+		}
+		clean.RawQuery = query.Encode() // Also sorts the remaining parameters
+	}
+	clean.ForceQuery = false
+
+	return &clean
+}
+
+// canonicalKey is the identity used to detect duplicate pages. On top of cleanURL
+// it resolves dot segments, collapses duplicate slashes, drops index files and
+// ignores trailing slashes, so /docs, /docs/ and /docs/index.html are one page.
+func canonicalKey(u *url.URL) string {
+	canonical := cleanURL(u)
+
+	p := canonical.Path
+	for strings.Contains(p, "//") {
+		p = strings.ReplaceAll(p, "//", "/")
+	}
+	if p != "" {
+		p = path.Clean("/" + p)
+	}
+	if indexFiles[strings.ToLower(path.Base(p))] {
+		p = path.Dir(p)
+	}
+	p = strings.TrimSuffix(p, "/")
+	if p == "" {
+		p = "/"
+	}
+
+	canonical.Path = p
+	canonical.RawPath = ""
+	return canonical.String()
+}
+
+// canonicalKeyString is canonicalKey for an unparsed URL
+func canonicalKeyString(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return canonicalKey(u)
+}
+
+// findCanonicalLink returns the page's <link rel="canonical"> resolved against pageURL, if any
+func findCanonicalLink(doc *goquery.Document, pageURL *url.URL) *url.URL {
+	var canonical *url.URL
+	doc.Find("link[rel][href]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		rel, _ := s.Attr("rel")
+		if !strings.EqualFold(strings.TrimSpace(rel), "canonical") {
+			return true
+		}
+
+		href, _ := s.Attr("href")
+		resolved, err := pageURL.Parse(strings.TrimSpace(href))
+		if err == nil && resolved.Host != "" {
+			canonical = resolved
+		}
+		return false
+	})
+	return canonical
+}
+
+// claimIdentity checks the URLs a fetched page is also known by, namely where
+// it redirected to and what its canonical link says, and claims them so the
+// page is not fetched again under those names. It returns the page's canonical
+// URL, or ok=false if another page already claimed it and this one is a duplicate.
+func (f *OptimizedFetcher) claimIdentity(item *workItem, finalURL string, doc *goquery.Document) (canonical string, ok bool) {
+	final, err := url.Parse(finalURL)
+	if err != nil {
+		return item.URL, true
+	}
+	return f.claimURLs(item, final, findCanonicalLink(doc, final))
+}
+
+// claimURLs claims a page's final URL and canonical link (nil if it has none)
+// as claimIdentity does
+func (f *OptimizedFetcher) claimURLs(item *workItem, final, link *url.URL) (canonical string, ok bool) {
+	ownKey := canonicalKeyString(item.URL)
+	canonical = cleanURL(final).String()
+
+	identities := []*url.URL{final}
+	if link != nil && link.Host == final.Host {
+		identities = append(identities, link)
+		canonical = cleanURL(link).String()
+	}
+
+	// A canonical link naming the URL redirected to is the same identity, not a duplicate
+	claimed := map[string]bool{ownKey: true}
+	for _, identity := range identities {
+		key := canonicalKey(identity)
+		if claimed[key] {
+			continue
+		}
+		claimed[key] = true
+		if _, loaded := f.visited.LoadOrStore(key, true); loaded {
+			return canonical, false
+		}
+	}
+
+	return canonical, true
+}
@@ -0,0 +1,426 @@
+package fetcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenEstimator approximates how many tokens a text costs a language model.
+// Estimators run offline; none of them needs the model's tokenizer.
+type TokenEstimator interface {
+	EstimateTokens(text string) int
+}
+
+// TokenEstimatorFunc adapts a function to a TokenEstimator
+type TokenEstimatorFunc func(text string) int
+
+// EstimateTokens calls f(text)
+func (f TokenEstimatorFunc) EstimateTokens(text string) int {
+	return f(text)
+}
+
+// tokenEstimators are the built-in estimators, by name
+var tokenEstimators = map[string]TokenEstimator{
+	// About four characters per token for English text with BPE tokenizers
+	"chars": TokenEstimatorFunc(func(text string) int {
+		return (utf8.RuneCountInString(text) + 3) / 4
+	}),
+	// About three tokens for every four words
+	"words": TokenEstimatorFunc(func(text string) int {
+		return (len(strings.Fields(text))*4 + 2) / 3
+	}),
+	"heuristic": TokenEstimatorFunc(estimateTokensHeuristic),
+}
+
+// DefaultTokenEstimator is used when a config names none
+const DefaultTokenEstimator = "heuristic"
+
+// LookupTokenEstimator returns a built-in estimator: "chars", "words" or "heuristic"
+func LookupTokenEstimator(name string) (TokenEstimator, error) {
+	estimator, ok := tokenEstimators[name]
+	if !ok {
+		names := make([]string, 0, len(tokenEstimators))
+		for name := range tokenEstimators {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown token estimator %q (use %s)", name, strings.Join(names, ", "))
+	}
+	return estimator, nil
+}
+
+// estimateTokensHeuristic approximates BPE tokenizers: short words are one
+// token, longer ones one per four characters, every punctuation mark is one
+// and CJK characters are one each. Code and Markdown syntax count higher than
+// with the character estimator, which is what real tokenizers do too.
+func estimateTokensHeuristic(text string) int {
+	tokens := 0
+	word := 0
+	endWord := func() {
+		if word > 0 {
+			tokens += (word + 3) / 4
+			word = 0
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			endWord()
+			tokens++
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word++
+		case unicode.IsSpace(r):
+			endWord()
+		default:
+			endWord()
+			tokens++
+		}
+	}
+	endWord()
+	return tokens
+}
+
+// MarkdownChunk is one piece of a Markdown document
+type MarkdownChunk struct {
+	Breadcrumb []string // Headings the chunk sits under, outermost first
+	Content    string
+	Tokens     int
+}
+
+// chunkBlock is a unit the chunker never splits across chunks, unless it is
+// an oversized paragraph
+type chunkBlock struct {
+	text    string
+	heading int  // Heading level, 0 for other blocks
+	atomic  bool // Code fence or table: kept whole even if it exceeds the limit
+}
+
+// ChunkMarkdown splits Markdown into chunks of at most maxTokens tokens.
+// Chunks start at headings; a chunk keeps the subsections of the heading it
+// starts at while they fit. Code fences and tables are never split, so a
+// single one larger than maxTokens becomes an oversized chunk of its own.
+// When a section has to be continued in a new chunk, the new chunk repeats
+// up to overlap tokens from the end of the previous one.
+func ChunkMarkdown(markdown string, maxTokens, overlap int, estimator TokenEstimator) []MarkdownChunk {
+	var chunks []MarkdownChunk
+	var trail []string     // Current heading path
+	var levels []int       // Level of each heading in trail
+	var parts []chunkBlock // Blocks of the chunk being built
+	var crumb []string     // Breadcrumb of the chunk being built
+	tokens := 0            // Tokens in parts
+	level := 0             // Level of the heading the chunk started at (0 = top of the page)
+
+	flush := func() {
+		texts := make([]string, len(parts))
+		for i, part := range parts {
+			texts[i] = part.text
+		}
+		if content := strings.TrimSpace(strings.Join(texts, "\n\n")); content != "" {
+			chunks = append(chunks, MarkdownChunk{
+				Breadcrumb: crumb,
+				Content:    content,
+				Tokens:     estimator.EstimateTokens(content),
+			})
+		}
+		parts, tokens = nil, 0
+	}
+	add := func(block chunkBlock) {
+		if len(parts) == 0 {
+			crumb = append([]string(nil), trail...)
+		}
+		parts = append(parts, block)
+		tokens += estimator.EstimateTokens(block.text)
+	}
+
+	for _, block := range markdownBlocks(markdown) {
+		cost := estimator.EstimateTokens(block.text)
+
+		if block.heading > 0 {
+			for len(levels) > 0 && levels[len(levels)-1] >= block.heading {
+				trail, levels = trail[:len(trail)-1], levels[:len(levels)-1]
+			}
+			trail = append(trail, headingText(block.text))
+			levels = append(levels, block.heading)
+
+			// A sibling or outer heading, or a subsection that no longer fits, starts a new chunk
+			if len(parts) > 0 && (block.heading <= level || tokens+cost > maxTokens) {
+				flush()
+			}
+			if len(parts) == 0 {
+				level = block.heading
+			}
+			add(block)
+			continue
+		}
+
+		// Leave room for the overlap when a long paragraph has to be cut up
+		pieces := []chunkBlock{block}
+		if !block.atomic && cost > maxTokens {
+			pieces = nil
+			for _, text := range splitText(block.text, maxTokens-max(overlap, 0), estimator) {
+				pieces = append(pieces, chunkBlock{text: text})
+			}
+		}
+
+		for i, piece := range pieces {
+			pieceCost := estimator.EstimateTokens(piece.text)
+			if len(parts) == 0 || tokens+pieceCost <= maxTokens {
+				add(piece)
+				continue
+			}
+
+			// Headings at the end of the full chunk move on with their content
+			cut := len(parts)
+			for cut > 0 && parts[cut-1].heading > 0 {
+				cut--
+			}
+			moved := append([]chunkBlock(nil), parts[cut:]...)
+			previous := chunkBlock{}
+			if cut > 0 {
+				previous = parts[cut-1]
+			}
+
+			if cut > 0 {
+				parts = parts[:cut]
+				flush()
+			} else {
+				parts, tokens = nil, 0
+			}
+
+			if len(moved) > 0 {
+				// The moved headings start the new chunk, so it sits under them
+				for _, heading := range moved {
+					add(heading)
+				}
+				level = moved[0].heading
+			} else if overlap > 0 && !previous.atomic && previous.heading == 0 {
+				// A section continued in a new chunk repeats the end of the previous text
+				if tail := textTail(previous.text, min(overlap, maxTokens-pieceCost), estimator); tail != "" {
+					// Within one cut-up paragraph the overlap runs on into the next piece
+					if i > 0 {
+						piece.text = tail + " " + piece.text
+					} else {
+						add(chunkBlock{text: tail})
+					}
+				}
+			}
+			add(piece)
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// markdownBlocks splits Markdown into headings, code fences, tables and
+// paragraphs (including whole list items)
+func markdownBlocks(markdown string) []chunkBlock {
+	lines := strings.Split(markdown, "\n")
+	var blocks []chunkBlock
+	var paragraph []string
+
+	endParagraph := func() {
+		if text := strings.TrimSpace(strings.Join(paragraph, "\n")); text != "" {
+			blocks = append(blocks, chunkBlock{text: strings.TrimRight(strings.Join(paragraph, "\n"), " \n")})
+		}
+		paragraph = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimLeft(line, " ")
+
+		switch {
+		case codeFenceMarker(trimmed) != "":
+			endParagraph()
+			fence := codeFenceMarker(trimmed)
+			start := i
+			for i+1 < len(lines) {
+				i++
+				if closesCodeFence(strings.TrimLeft(lines[i], " "), fence) {
+					break
+				}
+			}
+			blocks = append(blocks, chunkBlock{text: strings.Join(lines[start:i+1], "\n"), atomic: true})
+
+		case strings.HasPrefix(trimmed, "|"):
+			endParagraph()
+			start := i
+			for i+1 < len(lines) && strings.HasPrefix(strings.TrimLeft(lines[i+1], " "), "|") {
+				i++
+			}
+			blocks = append(blocks, chunkBlock{text: strings.Join(lines[start:i+1], "\n"), atomic: true})
+
+		case strings.HasPrefix(trimmed, "<table"):
+			endParagraph()
+			start := i
+			for !strings.Contains(lines[i], "</table>") && i+1 < len(lines) {
+				i++
+			}
+			blocks = append(blocks, chunkBlock{text: strings.Join(lines[start:i+1], "\n"), atomic: true})
+
+		case headingLevel(line) > 0:
+			endParagraph()
+			blocks = append(blocks, chunkBlock{text: line, heading: headingLevel(line)})
+
+		case strings.TrimSpace(line) == "":
+			endParagraph()
+
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	endParagraph()
+
+	return blocks
+}
+
+// headingLevel returns the level of an ATX heading line, or 0
+func headingLevel(line string) int {
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level < 1 || level > 6 || (len(line) > level && line[level] != ' ') {
+		return 0
+	}
+	return level
+}
+
+// headingText strips the markers from a heading line
+func headingText(line string) string {
+	text := strings.TrimSpace(strings.TrimLeft(line, "#"))
+	return strings.TrimSpace(strings.TrimRight(text, "#"))
+}
+
+// splitText breaks an oversized paragraph into pieces that fit, at line
+// breaks if it can, then at sentence ends, then between words
+func splitText(text string, maxTokens int, estimator TokenEstimator) []string {
+	for _, sep := range []string{"\n", ". ", " "} {
+		units := strings.SplitAfter(text, sep)
+		if len(units) < 2 {
+			continue
+		}
+
+		var pieces []string
+		current := ""
+		for _, unit := range units {
+			if current != "" && estimator.EstimateTokens(current+unit) > maxTokens {
+				pieces = append(pieces, strings.TrimSpace(current))
+				current = ""
+			}
+			current += unit
+		}
+		if strings.TrimSpace(current) != "" {
+			pieces = append(pieces, strings.TrimSpace(current))
+		}
+
+		// Pieces that are still too big are split at the next finer boundary
+		var result []string
+		for _, piece := range pieces {
+			if estimator.EstimateTokens(piece) > maxTokens && sep != " " {
+				result = append(result, splitText(piece, maxTokens, estimator)...)
+			} else {
+				result = append(result, piece)
+			}
+		}
+		return result
+	}
+	return []string{text}
+}
+
+// textTail returns the last words of text that fit in maxTokens
+func textTail(text string, maxTokens int, estimator TokenEstimator) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	words := strings.Fields(text)
+	start := len(words)
+	for start > 0 && estimator.EstimateTokens(strings.Join(words[start-1:], " ")) <= maxTokens {
+		start--
+	}
+	return strings.Join(words[start:], " ")
+}
+
+// ChunkRecord is one chunk of chunked JSONL output
+type ChunkRecord struct {
+	ID         string   `json:"id"` // Canonical URL and chunk index, e.g. "https://example.com/docs/intro#2"
+	URL        string   `json:"url"`
+	Title      string   `json:"title"`
+	Breadcrumb []string `json:"breadcrumb"` // Page title, then the headings the chunk sits under
+	Index      int      `json:"index"`
+	Chunks     int      `json:"chunks"` // Chunks in the page
+	Tokens     int      `json:"tokens"`
+	Content    string   `json:"content"`
+}
+
+// pageChunks splits a page into chunk records
+func pageChunks(page *PageResult, maxTokens, overlap int, estimator TokenEstimator) []ChunkRecord {
+	pageURL := page.CanonicalURL
+	if pageURL == "" {
+		pageURL = page.URL
+	}
+	title := CleanTitle(page.Title)
+
+	chunks := ChunkMarkdown(page.Content, maxTokens, overlap, estimator)
+	records := make([]ChunkRecord, len(chunks))
+	for i, chunk := range chunks {
+		breadcrumb := chunk.Breadcrumb
+		if len(breadcrumb) == 0 || !strings.EqualFold(breadcrumb[0], title) {
+			breadcrumb = append([]string{title}, breadcrumb...)
+		}
+
+		records[i] = ChunkRecord{
+			ID:         fmt.Sprintf("%s#%d", pageURL, i),
+			URL:        pageURL,
+			Title:      title,
+			Breadcrumb: breadcrumb,
+			Index:      i,
+			Chunks:     len(chunks),
+			Tokens:     chunk.Tokens,
+			Content:    chunk.Content,
+		}
+	}
+	return records
+}
+
+// writeResultsChunks writes every page as chunk records, one per line
+func writeResultsChunks(outputPath string, resultsChan <-chan *PageResult, maxTokens, overlap int, estimator TokenEstimator) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriterSize(file, 32*1024)
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+
+	count := 0
+	for result := range resultsChan {
+		if strings.TrimSpace(result.Content) == "" {
+			continue
+		}
+		for _, record := range pageChunks(result, maxTokens, overlap, estimator) {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+
+		count++
+		if count%10 == 0 {
+			writer.Flush()
+		}
+	}
+
+	// A full disk may only show up on the last flush or on close
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
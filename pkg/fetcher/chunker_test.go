@@ -0,0 +1,101 @@
+package fetcher
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// wordTokens counts one token per word, which keeps expected chunks readable
+var wordTokens = TokenEstimatorFunc(func(text string) int { return len(strings.Fields(text)) })
+
+func TestChunkMarkdown(t *testing.T) {
+	tests := []struct {
+		name      string
+		markdown  string
+		maxTokens int
+		overlap   int
+		want      []MarkdownChunk
+	}{
+		{
+			name:      "subsections stay with their heading while they fit",
+			markdown:  "# A\n\none two\n\n## B\n\nthree four",
+			maxTokens: 10,
+			want: []MarkdownChunk{
+				{Breadcrumb: []string{"A"}, Content: "# A\n\none two\n\n## B\n\nthree four", Tokens: 8},
+			},
+		},
+		{
+			name:      "sibling heading starts a new chunk",
+			markdown:  "# A\n\none two\n\n# B\n\nthree four",
+			maxTokens: 10,
+			want: []MarkdownChunk{
+				{Breadcrumb: []string{"A"}, Content: "# A\n\none two", Tokens: 4},
+				{Breadcrumb: []string{"B"}, Content: "# B\n\nthree four", Tokens: 4},
+			},
+		},
+		{
+			name:      "subsection that does not fit moves on with its heading",
+			markdown:  "# A\n\none two three\n\n## B\n\nfour five six seven",
+			maxTokens: 6,
+			want: []MarkdownChunk{
+				{Breadcrumb: []string{"A"}, Content: "# A\n\none two three", Tokens: 5},
+				{Breadcrumb: []string{"A", "B"}, Content: "## B\n\nfour five six seven", Tokens: 6},
+			},
+		},
+		{
+			name:      "code fence is never split",
+			markdown:  "# A\n\n```\none two three four five six\n```\n\nafter",
+			maxTokens: 4,
+			want: []MarkdownChunk{
+				{Breadcrumb: []string{"A"}, Content: "# A\n\n```\none two three four five six\n```", Tokens: 10},
+				{Breadcrumb: []string{"A"}, Content: "after", Tokens: 1},
+			},
+		},
+		{
+			name:      "long paragraph pieces overlap",
+			markdown:  "one two three four five six seven eight nine ten",
+			maxTokens: 6,
+			overlap:   2,
+			want: []MarkdownChunk{
+				{Content: "one two three four", Tokens: 4},
+				{Content: "three four five six seven eight", Tokens: 6},
+				{Content: "seven eight nine ten", Tokens: 4},
+			},
+		},
+		{
+			name:      "continued section repeats the end of the previous paragraph",
+			markdown:  "# A\n\none two three four\n\nfive six seven eight",
+			maxTokens: 6,
+			overlap:   2,
+			want: []MarkdownChunk{
+				{Breadcrumb: []string{"A"}, Content: "# A\n\none two three four", Tokens: 6},
+				{Breadcrumb: []string{"A"}, Content: "three four\n\nfive six seven eight", Tokens: 6},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ChunkMarkdown(tt.markdown, tt.maxTokens, tt.overlap, wordTokens)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ChunkMarkdown() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteResultsChunksReportsFullDisk(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full on this system")
+	}
+
+	results := make(chan *PageResult, 1)
+	results <- &PageResult{URL: "https://docs.example.com/", Title: "Home", Content: "Welcome"}
+	close(results)
+
+	if err := writeResultsChunks("/dev/full", results, 100, 0, wordTokens); err == nil {
+		t.Error("writeResultsChunks() = nil, want the error of the final flush")
+	}
+}
@@ -0,0 +1,323 @@
+package fetcher
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// codeChromeClasses mark parts of a highlighted code block that are not source:
+// line-number gutters (Prism, highlight.js plugins, Chroma, Pygments, Hexo, GitHub)
+// and copy-to-clipboard widgets
+var codeChromeClasses = map[string]bool{
+	"ln":                true, // Chroma inline line numbers
+	"lnt":               true, // Chroma table line numbers
+	"linenos":           true, // Pygments
+	"lineno":            true,
+	"linenodiv":         true,
+	"line-number":       true,
+	"line-numbers-rows": true, // Prism line-numbers plugin
+	"hljs-ln-numbers":   true, // highlightjs-line-numbers.js
+	"gutter":            true, // Hexo, Jekyll
+	"blob-num":          true, // GitHub
+	"copy":              true,
+	"copybtn":           true, // sphinx-copybutton
+	"copy-button":       true,
+	"copy-code-button":  true,
+	"code-copy":         true,
+	"clipboard":         true,
+	"md-clipboard":      true, // MkDocs Material
+}
+
+// codeLineClasses mark elements that hold exactly one line of a highlighted block.
+// Some highlighters (Docusaurus' Prism, Shiki, Chroma) emit these without a newline between them.
+var codeLineClasses = map[string]bool{
+	"line":           true, // Chroma, Shiki
+	"token-line":     true, // Prism in Docusaurus
+	"code-line":      true,
+	"view-line":      true, // Monaco
+	"highlight-line": true,
+}
+
+// codeLanguageClass matches language hints such as language-go, lang-python,
+// highlight-python (Sphinx) or highlight-source-js (GitHub)
+var codeLanguageClass = regexp.MustCompile(`^(?:language|lang|highlight-source|highlight)-([A-Za-z0-9_+#.-]+)$`)
+
+// bareLanguageClasses are language names highlighters put directly in the class list,
+// e.g. <code class="python hljs"> or Pandoc's <pre class="sourceCode python">
+var bareLanguageClasses = map[string]bool{
+	"bash": true, "c": true, "console": true, "cpp": true, "csharp": true, "css": true,
+	"diff": true, "dockerfile": true, "go": true, "graphql": true, "html": true,
+	"ini": true, "java": true, "javascript": true, "js": true, "json": true, "jsx": true,
+	"kotlin": true, "lua": true, "makefile": true, "php": true, "powershell": true,
+	"py": true, "python": true, "ruby": true, "rust": true, "scala": true, "sh": true,
+	"shell": true, "sql": true, "swift": true, "toml": true, "ts": true, "tsx": true,
+	"typescript": true, "xml": true, "yaml": true, "yml": true,
+}
+
+// ignoredLanguages are hints that say nothing about the language
+var ignoredLanguages = map[string]bool{
+	"none": true, "plaintext": true, "plain": true, "text": true, "txt": true, "default": true,
+}
+
+// codeBlock renders <pre> as a fenced code block holding the exact source text
+func (mc *markdownConverter) codeBlock(pre *html.Node) string {
+	code := codeText(pre)
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+
+	fence := codeFence(code)
+	return fence + codeLanguage(pre, code) + "\n" + code + "\n" + fence
+}
+
+// codeText rebuilds the source text of a code block from its highlighter markup,
+// keeping indentation and restoring line breaks between per-line elements
+func codeText(pre *html.Node) string {
+	var sb strings.Builder
+	needNewline := false
+	endsLine := true
+
+	write := func(s string) {
+		if s == "" {
+			return
+		}
+		if needNewline && !strings.HasPrefix(s, "\n") {
+			sb.WriteByte('\n')
+		}
+		needNewline = false
+		sb.WriteString(s)
+		endsLine = strings.HasSuffix(s, "\n")
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			write(n.Data)
+			return
+		case html.ElementNode:
+		default:
+			return
+		}
+
+		if n.DataAtom == atom.Br {
+			write("\n")
+			return
+		}
+		if skippedElements[n.DataAtom] || isHidden(n) || isCodeChrome(n) {
+			return
+		}
+
+		line := isCodeLine(n)
+		if line && needNewline {
+			sb.WriteByte('\n')
+			needNewline = false
+			endsLine = true
+		}
+		start := sb.Len()
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+
+		// A line element (or a block/row inside <pre>) ends its line even without a
+		// newline; an empty one is a blank line
+		if line && (sb.Len() == start || !endsLine) {
+			needNewline = true
+		}
+	}
+	for c := pre.FirstChild; c != nil; c = c.NextSibling {
+		walk(c)
+	}
+
+	code := strings.ReplaceAll(sb.String(), "\r\n", "\n")
+	code = strings.ReplaceAll(code, "\u00a0", " ")
+
+	// HTML drops a single newline right after <pre>; trailing blank lines carry no meaning
+	code = strings.TrimPrefix(code, "\n")
+	return strings.TrimRight(code, "\n \t")
+}
+
+// isCodeLine reports whether an element inside a code block holds one line
+func isCodeLine(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Div, atom.P, atom.Tr:
+		return true
+	}
+	for _, class := range strings.Fields(getAttr(n, "class")) {
+		if codeLineClasses[class] {
+			return true
+		}
+	}
+	return false
+}
+
+// isCodeChrome reports whether an element is a line-number gutter, copy button
+// or language label rather than source. The class names are generic, so this
+// only applies inside a code block or its highlighter's wrapper.
+func isCodeChrome(n *html.Node) bool {
+	classes := strings.Fields(getAttr(n, "class"))
+	for _, class := range classes {
+		if codeChromeClasses[class] {
+			return true
+		}
+	}
+
+	// VitePress and others print the language as a label next to the <pre>
+	return len(classes) == 1 && (classes[0] == "lang" || classes[0] == "code-lang")
+}
+
+// isCodeWrapperChrome reports whether an element is code chrome sitting next
+// to a <pre> in the highlighter's wrapper, such as a copy button or language label
+func isCodeWrapperChrome(n *html.Node) bool {
+	if n.Parent == nil {
+		return false
+	}
+	for c := n.Parent.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Pre {
+			return isCodeChrome(n)
+		}
+	}
+	return false
+}
+
+// codeTablePre returns the code <pre> of a line-numbered code table
+// (Pygments "highlighttable", Chroma "lntable", Hexo gutter tables), or nil
+// if the table is an ordinary data table
+func codeTablePre(table *html.Node) *html.Node {
+	var codeCell *html.Node
+	hasGutter := false
+	for _, tr := range tableRows(table) {
+		for td := tr.FirstChild; td != nil; td = td.NextSibling {
+			if td.Type != html.ElementNode || td.DataAtom != atom.Td {
+				continue
+			}
+			if isCodeChrome(td) || hasClass(td, "lntd") {
+				hasGutter = true
+			}
+			if findChild(td, atom.Pre) != nil {
+				codeCell = td
+			}
+		}
+	}
+
+	if !hasGutter || codeCell == nil {
+		return nil
+	}
+	return findChild(codeCell, atom.Pre)
+}
+
+// codeFence returns a backtick fence longer than any backtick run inside the code
+func codeFence(code string) string {
+	longest := 0
+	run := 0
+	for _, r := range code {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// codeLanguage finds the language of a code block from hints on the <pre>, its
+// <code> child or the highlighter's wrapper elements, then from the code itself
+func codeLanguage(pre *html.Node, code string) string {
+	candidates := []*html.Node{}
+	for c := pre.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Code {
+			candidates = append(candidates, c)
+		}
+	}
+	candidates = append(candidates, pre)
+
+	// Sphinx, VitePress and Hugo put the hint on a wrapper a few levels up
+	for p, i := pre.Parent, 0; p != nil && p.Type == html.ElementNode && i < 6; p, i = p.Parent, i+1 {
+		candidates = append(candidates, p)
+	}
+
+	for _, n := range candidates {
+		if lang := languageHint(n); lang != "" {
+			return lang
+		}
+	}
+	return sniffLanguage(code)
+}
+
+// languageHint reads a language from data-lang/data-language or a class on one element
+func languageHint(n *html.Node) string {
+	for _, attr := range []string{"data-lang", "data-language"} {
+		if lang := strings.ToLower(strings.TrimSpace(getAttr(n, attr))); lang != "" && !ignoredLanguages[lang] {
+			return lang
+		}
+	}
+
+	classes := strings.Fields(getAttr(n, "class"))
+	for _, class := range classes {
+		if m := codeLanguageClass.FindStringSubmatch(class); m != nil {
+			if lang := strings.ToLower(m[1]); !ignoredLanguages[lang] {
+				return lang
+			}
+		}
+	}
+
+	// Bare names only count on elements that are clearly highlighted code
+	if n.DataAtom == atom.Code || n.DataAtom == atom.Pre {
+		for _, class := range classes {
+			if bareLanguageClasses[strings.ToLower(class)] {
+				return strings.ToLower(class)
+			}
+		}
+	}
+	return ""
+}
+
+// shebangLanguages maps interpreters in a #! line to a language
+var shebangLanguages = map[string]string{
+	"bash": "bash", "sh": "sh", "zsh": "zsh", "python": "python", "python3": "python",
+	"node": "javascript", "ruby": "ruby", "perl": "perl", "php": "php",
+}
+
+// sniffLanguage recognizes code whose first line names its language unambiguously
+func sniffLanguage(code string) string {
+	first, _, _ := strings.Cut(strings.TrimSpace(code), "\n")
+
+	switch {
+	case strings.HasPrefix(first, "<?php"):
+		return "php"
+	case strings.HasPrefix(first, "<?xml"):
+		return "xml"
+	case strings.HasPrefix(first, "#!"):
+		fields := strings.Fields(strings.TrimPrefix(first, "#!"))
+		if len(fields) == 0 {
+			return ""
+		}
+		interpreter := fields[0][strings.LastIndex(fields[0], "/")+1:]
+		if interpreter == "env" && len(fields) > 1 {
+			interpreter = fields[1]
+		}
+		return shebangLanguages[interpreter]
+	}
+	return ""
+}
+
+// hasClass reports whether an element has the given class
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(getAttr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
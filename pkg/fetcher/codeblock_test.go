@@ -0,0 +1,40 @@
+package fetcher
+
+import "testing"
+
+func TestConvertHTMLToMarkdownCodeChrome(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "chrome class names outside code blocks are content",
+			html: `<p class="copy">Marketing copy stays.</p><div class="gutter">Gutter column</div>`,
+			want: "Marketing copy stays.\n\nGutter column",
+		},
+		{
+			name: "copy button and language label next to the pre",
+			html: `<div class="language-js"><button class="copy">Copy</button><span class="lang">js</span><pre><code>let a = 1</code></pre></div>`,
+			want: "```js\nlet a = 1\n```",
+		},
+		{
+			name: "line numbers inside the pre",
+			html: `<pre><code><span class="ln">1</span>echo hi</code></pre>`,
+			want: "```\necho hi\n```",
+		},
+		{
+			name: "table cell named like a line number column",
+			html: `<table><tr><th>A</th></tr><tr><td class="ln">cell</td></tr></table>`,
+			want: "| A |\n| --- |\n| cell |",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ConvertHTMLToMarkdown(tt.html); got != tt.want {
+				t.Errorf("ConvertHTMLToMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
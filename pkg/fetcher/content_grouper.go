@@ -2,9 +2,19 @@ package fetcher
 
 import (
 	"fmt"
+	"os"
 	"regexp"
 	"sort"
 	"strings"
+	"unicode"
+)
+
+// Grouped output uses these patterns to clean up page content
+var (
+	blankLines  = regexp.MustCompile(`\n{3,}`)
+	spaceRuns   = regexp.MustCompile(`(\S)  +`)
+	tocLines    = regexp.MustCompile(`(?m)^(Table of Contents|Contents|On this page).*?\n{2,}`)
+	breadcrumbs = regexp.MustCompile(`(?m)^Home\s*›.*?\n{2,}`)
 )
 
 // ContentGroup represents a logically grouped section of documentation
@@ -20,13 +30,15 @@ type PageContent struct {
 	URL     string
 	Title   string
 	Content string
-	Level   int // Heading level (h1=1, h2=2, etc.)
+	Level   int   // Heading level (h1=1, h2=2, etc.)
+	Order   int64 // Discovery order, breaks ties between pages with the same title
 }
 
 // ContentGrouper organizes fetched content intelligently
 type ContentGrouper struct {
 	groups        map[string]*ContentGroup
 	pageExtractor *PageTypeExtractor
+	nav           *NavTree // When set, pages follow the sidebar instead of their titles
 }
 
 // NewContentGrouper creates a new content grouper
@@ -39,30 +51,30 @@ func NewContentGrouper() *ContentGrouper {
 
 // AddPage adds a page to appropriate group
 func (cg *ContentGrouper) AddPage(url, title, content string) {
-	// Extract page type and section
-	pageType := cg.pageExtractor.ExtractType(url, title)
-	section := cg.extractSection(url, title)
+	cg.addPage(PageContent{URL: url, Title: title, Content: content})
+}
+
+// addPage files a page under its category, keeping its discovery order
+func (cg *ContentGrouper) addPage(page PageContent) {
+	// Extract page type
+	pageType := cg.pageExtractor.ExtractType(page.URL, page.Title)
 	
 	// Create or get group
-	groupKey := fmt.Sprintf("%s_%s", pageType.Category, section)
-	group, exists := cg.groups[groupKey]
+	group, exists := cg.groups[pageType.Category]
 	if !exists {
 		group = &ContentGroup{
-			Title:       cg.formatGroupTitle(pageType.Category, section),
+			Title:       cg.formatGroupTitle(pageType.Category),
 			Description: pageType.Description,
 			Pages:       make([]PageContent, 0),
 			Order:       cg.getOrderForCategory(pageType.Category),
 		}
-		cg.groups[groupKey] = group
+		cg.groups[pageType.Category] = group
 	}
 	
-	// Add page to group
-	group.Pages = append(group.Pages, PageContent{
-		URL:     url,
-		Title:   title,
-		Content: cg.cleanContent(content),
-		Level:   cg.detectHeadingLevel(title),
-	})
+	// Pages sit under their group's H2
+	page.Content = cg.cleanContent(page.Content)
+	page.Level = 3
+	group.Pages = append(group.Pages, page)
 }
 
 // GenerateMarkdown produces well-organized markdown output
@@ -74,12 +86,29 @@ func (cg *ContentGrouper) GenerateMarkdown() string {
 	sb.WriteString("Generated by DocFetch - Intelligent documentation fetcher\n\n")
 	sb.WriteString("---\n\n")
 	
+	// Anchors are handed out in document order, so every heading is counted
+	// before the table of contents that links to them is written
+	sortedGroups := cg.getSortedGroups()
+	anchors := headingAnchors{}
+	anchors.anchor("Documentation")
+	anchors.anchor("Table of Contents")
+	groupIDs := make([]string, len(sortedGroups))
+	pageIDs := make([][]string, len(sortedGroups))
+	for i, group := range sortedGroups {
+		groupIDs[i] = anchors.anchor(group.Title)
+		for _, page := range group.Pages {
+			pageIDs[i] = append(pageIDs[i], anchors.anchor(page.Title))
+			for _, heading := range markdownHeadings(pageBody(page)) {
+				anchors.anchor(heading)
+			}
+		}
+	}
+	
 	// Write table of contents
 	sb.WriteString("## Table of Contents\n\n")
-	cg.writeTableOfContents(&sb)
+	cg.writeTableOfContents(&sb, sortedGroups, groupIDs, pageIDs)
 	
 	// Write grouped content
-	sortedGroups := cg.getSortedGroups()
 	for i, group := range sortedGroups {
 		if i > 0 {
 			sb.WriteString("\n---\n\n")
@@ -91,18 +120,15 @@ func (cg *ContentGrouper) GenerateMarkdown() string {
 }
 
 // writeTableOfContents generates a structured TOC
-func (cg *ContentGrouper) writeTableOfContents(sb *strings.Builder) {
-	sortedGroups := cg.getSortedGroups()
-	
-	for _, group := range sortedGroups {
+func (cg *ContentGrouper) writeTableOfContents(sb *strings.Builder, groups []*ContentGroup, groupIDs []string, pageIDs [][]string) {
+	for i, group := range groups {
 		// Group heading
-		groupID := cg.slugify(group.Title)
-		fmt.Fprintf(sb, "- [%s](#%s)\n", group.Title, groupID)
+		fmt.Fprintf(sb, "- [%s](#%s)\n", escapeInline(group.Title), groupIDs[i])
 		
 		// Pages in group
-		for _, page := range group.Pages {
-			pageID := cg.slugify(page.Title)
-			fmt.Fprintf(sb, "  - [%s](#%s)\n", page.Title, pageID)
+		for j, page := range group.Pages {
+			indent := strings.Repeat("  ", max(page.Level-2, 1))
+			fmt.Fprintf(sb, "%s- [%s](#%s)\n", indent, escapeInline(page.Title), pageIDs[i][j])
 		}
 	}
 	sb.WriteString("\n")
@@ -117,78 +143,67 @@ func (cg *ContentGrouper) writeGroup(sb *strings.Builder, group *ContentGroup) {
 		fmt.Fprintf(sb, "%s\n\n", group.Description)
 	}
 	
-	// Sort pages within group
-	cg.sortPages(group.Pages)
-	
 	// Write each page
 	for _, page := range group.Pages {
-		// Page heading based on detected level
-		prefix := strings.Repeat("#", page.Level+1)
-		fmt.Fprintf(sb, "%s %s\n\n", prefix, page.Title)
+		fmt.Fprintf(sb, "%s %s\n\n", strings.Repeat("#", page.Level), page.Title)
 		
 		// Add source link
 		fmt.Fprintf(sb, "*Source: [%s](%s)*\n\n", page.URL, page.URL)
 		
 		// Content
-		fmt.Fprintf(sb, "%s\n\n", page.Content)
+		fmt.Fprintf(sb, "%s\n\n", pageBody(page))
 	}
 }
 
+// pageBody is a page's content with its headings moved below the page heading
+func pageBody(page PageContent) string {
+	return shiftHeadings(page.Content, page.Level)
+}
+
 // Helper methods
 
-func (cg *ContentGrouper) extractSection(url, title string) string {
-	// Try to extract section from URL
-	parts := strings.Split(strings.TrimSuffix(url, "/"), "/")
-	if len(parts) > 0 {
-		lastPart := parts[len(parts)-1]
-		if lastPart != "" && !strings.Contains(lastPart, ".") {
-			return cg.humanize(lastPart)
+func (cg *ContentGrouper) cleanContent(content string) string {
+	// Remove excessive whitespace; code blocks and indentation are left alone
+	lines := strings.Split(content, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if fence != "" {
+			if closesCodeFence(trimmed, fence) {
+				fence = ""
+			}
+			continue
 		}
+		if marker := codeFenceMarker(trimmed); marker != "" {
+			fence = marker
+			continue
+		}
+		lines[i] = spaceRuns.ReplaceAllString(line, "$1 ")
 	}
-	
-	// Fallback to first part of title
-	fields := strings.Fields(title)
-	if len(fields) > 0 {
-		return fields[0]
-	}
-	
-	return "general"
-}
-
-func (cg *ContentGrouper) cleanContent(content string) string {
-	// Remove excessive whitespace
-	content = regexp.MustCompile(`\n{3,}`).ReplaceAllString(content, "\n\n")
-	content = regexp.MustCompile(`  +`).ReplaceAllString(content, " ")
+	content = strings.Join(lines, "\n")
+	content = blankLines.ReplaceAllString(content, "\n\n")
 	
 	// Remove navigation elements that slipped through
-	content = regexp.MustCompile(`(?m)^(Table of Contents|Contents|On this page).*?\n{2,}`).ReplaceAllString(content, "")
+	content = tocLines.ReplaceAllString(content, "")
 	
 	// Remove breadcrumb trails
-	content = regexp.MustCompile(`(?m)^Home\s*›.*?\n{2,}`).ReplaceAllString(content, "")
+	content = breadcrumbs.ReplaceAllString(content, "")
 	
 	return strings.TrimSpace(content)
 }
 
-func (cg *ContentGrouper) detectHeadingLevel(title string) int {
-	// Count words - shorter titles tend to be higher level
-	words := len(strings.Fields(title))
-	
-	if words <= 3 {
-		return 1 // H2 (H1 is document title)
-	} else if words <= 6 {
-		return 2 // H3
-	}
-	return 3 // H4
+// groupTitles name the categories whose title is not just the humanized name
+var groupTitles = map[string]string{
+	"api":     "API Reference",
+	"faq":     "FAQ",
+	"general": "General",
 }
 
-func (cg *ContentGrouper) formatGroupTitle(category, section string) string {
-	category = cg.humanize(category)
-	section = cg.humanize(section)
-	
-	if section == "general" {
-		return category
+func (cg *ContentGrouper) formatGroupTitle(category string) string {
+	if title, ok := groupTitles[category]; ok {
+		return title
 	}
-	return fmt.Sprintf("%s - %s", category, section)
+	return cg.humanize(category)
 }
 
 func (cg *ContentGrouper) getOrderForCategory(category string) int {
@@ -219,23 +234,98 @@ func (cg *ContentGrouper) getSortedGroups() []*ContentGroup {
 	}
 	
 	sort.Slice(groups, func(i, j int) bool {
-		return groups[i].Order < groups[j].Order
+		if groups[i].Order != groups[j].Order {
+			return groups[i].Order < groups[j].Order
+		}
+		return groups[i].Title < groups[j].Title
 	})
 	
+	for _, group := range groups {
+		cg.sortPages(group.Pages)
+	}
 	return groups
 }
 
 func (cg *ContentGrouper) sortPages(pages []PageContent) {
-	sort.Slice(pages, func(i, j int) bool {
-		return pages[i].Title < pages[j].Title
+	sort.SliceStable(pages, func(i, j int) bool {
+		// Pages in the sidebar come first, in sidebar order
+		rankI, _, inNavI := cg.nav.Position(pages[i].URL)
+		rankJ, _, inNavJ := cg.nav.Position(pages[j].URL)
+		if inNavI != inNavJ {
+			return inNavI
+		}
+		if rankI != rankJ {
+			return rankI < rankJ
+		}
+		if pages[i].Title != pages[j].Title {
+			return pages[i].Title < pages[j].Title
+		}
+		if pages[i].Order != pages[j].Order {
+			return pages[i].Order < pages[j].Order
+		}
+		return pages[i].URL < pages[j].URL
 	})
 }
 
-func (cg *ContentGrouper) slugify(text string) string {
-	text = strings.ToLower(text)
-	text = regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(text, "-")
-	text = strings.Trim(text, "-")
-	return text
+// headingAnchors hands out the anchors GitHub-style renderers give headings,
+// numbering repeats: "setup", "setup-1", "setup-2"
+type headingAnchors map[string]int
+
+func (a headingAnchors) anchor(text string) string {
+	slug := headingSlug(text)
+	for {
+		n := a[slug]
+		a[slug] = n + 1
+		if n == 0 {
+			return slug
+		}
+		// "Setup" twice must not collide with a heading that really is "Setup 1"
+		numbered := fmt.Sprintf("%s-%d", slug, n)
+		if a[numbered] == 0 {
+			a[numbered] = 1
+			return numbered
+		}
+	}
+}
+
+// headingSlug lowercases heading text, drops punctuation and turns spaces into dashes
+func headingSlug(text string) string {
+	// Links and images slug by their text
+	text = markdownLink.ReplaceAllString(text, "$2")
+	
+	var sb strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			sb.WriteRune(r)
+		case r == ' ':
+			sb.WriteRune('-')
+		}
+	}
+	return sb.String()
+}
+
+// markdownHeadings lists the text of every ATX heading outside code blocks
+func markdownHeadings(markdown string) []string {
+	var headings []string
+	fence := ""
+	for _, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if fence != "" {
+			if closesCodeFence(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if marker := codeFenceMarker(trimmed); marker != "" {
+			fence = marker
+			continue
+		}
+		if headingLevel(line) > 0 {
+			headings = append(headings, headingText(line))
+		}
+	}
+	return headings
 }
 
 func (cg *ContentGrouper) humanize(text string) string {
@@ -255,6 +345,7 @@ func (cg *ContentGrouper) humanize(text string) string {
 
 // PageTypeExtractor identifies the type of documentation page
 type PageTypeExtractor struct {
+	categories   []string // Checked in this order, so a page matching several patterns always gets the same one
 	patterns     map[string]*regexp.Regexp
 	descriptions map[string]string
 }
@@ -268,6 +359,7 @@ type PageTypeInfo struct {
 // NewPageTypeExtractor creates a new page type extractor
 func NewPageTypeExtractor() *PageTypeExtractor {
 	return &PageTypeExtractor{
+		categories: []string{"getting-started", "installation", "quickstart", "tutorial", "api", "example", "faq", "troubleshoot"},
 		patterns: map[string]*regexp.Regexp{
 			"getting-started": regexp.MustCompile(`(?i)(getting[-_]?started|intro|overview)`),
 			"installation":    regexp.MustCompile(`(?i)(install|setup|configure|build)`),
@@ -295,8 +387,8 @@ func NewPageTypeExtractor() *PageTypeExtractor {
 func (pe *PageTypeExtractor) ExtractType(url, title string) PageTypeInfo {
 	text := url + " " + title
 	
-	for category, pattern := range pe.patterns {
-		if pattern.MatchString(text) {
+	for _, category := range pe.categories {
+		if pe.patterns[category].MatchString(text) {
 			return PageTypeInfo{
 				Category:    category,
 				Description: pe.descriptions[category],
@@ -310,3 +402,27 @@ func (pe *PageTypeExtractor) ExtractType(url, title string) PageTypeInfo {
 		Description: "General documentation",
 	}
 }
+
+// writeResultsGrouped collects every page, then writes them grouped by
+// category behind a table of contents. Grouping needs the whole site, so
+// pages are held in memory until the crawl ends. With nav, pages within a
+// group follow the site's sidebar.
+func writeResultsGrouped(outputPath string, resultsChan <-chan *PageResult, nav func() *NavTree) error {
+	grouper := NewContentGrouper()
+	for result := range resultsChan {
+		if strings.TrimSpace(result.Content) == "" {
+			continue
+		}
+		grouper.addPage(PageContent{
+			URL:     result.URL,
+			Title:   strings.Join(strings.Fields(result.Title), " "),
+			Content: result.Content,
+			Order:   result.Order,
+		})
+	}
+
+	if nav != nil {
+		grouper.nav = nav()
+	}
+	return os.WriteFile(outputPath, []byte(grouper.GenerateMarkdown()), 0644)
+}
@@ -1,6 +1,7 @@
 package fetcher
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
@@ -11,17 +12,51 @@ import (
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
-	"golang.org/x/net/html"
 )
 
 // Config holds the configuration for the documentation fetcher
 type Config struct {
-	BaseURL         string
-	OutputPath      string
-	MaxDepth        int
-	Workers         int
-	UserAgent       string
-	GenerateLLMTxt  bool
+	BaseURL             string
+	OutputPath          string
+	OutputDir           string         // Write one file per page under this directory instead of OutputPath
+	Format              string         // Single-file output format: "markdown" (default), "jsonl" or "chunks"
+	Grouped             bool           // Markdown only: group pages by category behind a table of contents
+	NavOrder            bool           // Markdown only: order pages and nest their headings as the site's sidebar does
+	Reproducible        bool           // Stable page order, no timestamps and normalized whitespace
+	ChunkTokens         int            // Chunk size limit for the chunks format (0 = 512)
+	ChunkOverlap        int            // Tokens a continued section repeats from its previous chunk (0 = ChunkTokens/8, negative disables)
+	TokenEstimator      TokenEstimator // Counts tokens for chunking (nil = the "heuristic" estimator)
+	MaxDepth            int
+	Workers             int
+	UserAgent           string
+	GenerateLLMTxt      bool
+	LLMTxtFormat        string        // "spec" (default): llms.txt and llms-full.txt; "legacy": the original llm.txt
+	LLMTxtSections      string        // How llms.txt is split into sections: "auto" (default), "nav" or "type"
+	MaxPages            int           // Stop after this many pages (0 = unlimited)
+	Timeout             time.Duration // Overall crawl deadline (0 = 10 minutes)
+	IgnoreRobots        bool          // Skip robots.txt checks (only for sites you own)
+	UseSitemap          bool          // Seed the crawl from the site's sitemap.xml
+	SitemapURL          string        // Explicit sitemap or sitemap index URL (implies UseSitemap)
+	SitemapLastModOrder bool          // Fetch sitemap pages newest <lastmod> first
+	RateLimit           float64       // Requests per second per host (0 = 5)
+	RateBurst           int           // Requests allowed in a burst per host (0 = 5)
+	AdaptiveRateLimit   bool          // Back off on 429/503 and honor Retry-After
+	MaxRetries          int           // Retries per page for transient failures (0 = 3, negative disables)
+	RetryBudget         int           // Retries allowed across the whole run (0 = 100)
+	RetryBaseDelay      time.Duration // First retry backoff, doubled each attempt (0 = 500ms)
+	RetryMaxDelay       time.Duration // Longest backoff between retries (0 = 30s)
+	IncludePatterns     []string      // Only follow URLs matching one of these globs (or "re:" regexes)
+	ExcludePatterns     []string      // Never follow URLs matching these globs (or "re:" regexes)
+	ScopePrefix         string        // Path prefix to stay under ("" = derived from BaseURL)
+	NoPrefixLock        bool          // Follow links anywhere on the host
+	DryRun              bool          // Crawl and report scope decisions without writing output
+	TablePolicy         string        // Rendering for tables with colspan/rowspan: "html" (default) or "list"
+	SiteConfigPath      string        // JSON file of per-site extraction rules
+	SiteRules           []SiteRule    // Per-site extraction rules, checked before those from SiteConfigPath
+	ReportPath          string        // Write a JSON extraction report here ("" = no report)
+	StatePath           string        // Incremental crawl state: ETags, Last-Modified and Markdown of every page ("" = fetch everything)
+	CacheDir            string        // Keep raw responses here and answer repeated requests from disk ("" = no cache)
+	Offline             bool          // Serve every request from CacheDir and never touch the network
 }
 
 // Page represents a fetched documentation page
@@ -38,6 +73,8 @@ type LLMTxtEntry struct {
 	Title       string
 	URL         string
 	Description string
+	Content     string // Page content for llms-full.txt
+	Order       int64  // Discovery order within the crawl
 }
 
 // Run executes the documentation fetching process
@@ -52,6 +89,7 @@ func Run(config Config) error {
 	// Create a visited map to avoid duplicate fetching
 	visited := make(map[string]bool)
 	var mutex sync.Mutex
+	limiter := newRateLimiter(config.RateLimit, config.RateBurst, config.AdaptiveRateLimit)
 	
 	// Create channel for pages and results
 	pagesChan := make(chan *Page, config.Workers*2)
@@ -64,7 +102,7 @@ func Run(config Config) error {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			worker(config, pagesChan, resultsChan, &mutex, visited, &llmEntries)
+			worker(config, pagesChan, resultsChan, &mutex, visited, &llmEntries, limiter)
 		}()
 	}
 	
@@ -99,7 +137,7 @@ func Run(config Config) error {
 }
 
 // worker processes pages from the channel
-func worker(config Config, pagesChan <-chan *Page, resultsChan chan<- string, mutex *sync.Mutex, visited map[string]bool, llmEntries *[]LLMTxtEntry) {
+func worker(config Config, pagesChan <-chan *Page, resultsChan chan<- string, mutex *sync.Mutex, visited map[string]bool, llmEntries *[]LLMTxtEntry, limiter *rateLimiter) {
 	client := &http.Client{
 		Timeout: 30 * time.Second,
 		// Add transport with security restrictions
@@ -125,9 +163,6 @@ func worker(config Config, pagesChan <-chan *Page, resultsChan chan<- string, mu
 		
 		log.Printf("Fetching: %s", page.URL)
 		
-		// Rate limiting - be respectful to servers
-		time.Sleep(100 * time.Millisecond)
-		
 		// Fetch the page
 		req, err := http.NewRequest("GET", page.URL, nil)
 		if err != nil {
@@ -136,12 +171,18 @@ func worker(config Config, pagesChan <-chan *Page, resultsChan chan<- string, mu
 		}
 		req.Header.Set("User-Agent", config.UserAgent)
 		
+		// Rate limiting - be respectful to servers
+		if err := limiter.wait(context.Background(), req.URL.Host); err != nil {
+			continue
+		}
+		
 		resp, err := client.Do(req)
 		if err != nil {
 			log.Printf("Error fetching %s: %v", page.URL, err)
 			continue
 		}
 		defer resp.Body.Close()
+		limiter.observe(req.URL.Host, resp)
 		
 		if resp.StatusCode != 200 {
 			log.Printf("Non-200 status code %d for %s", resp.StatusCode, page.URL)
@@ -154,6 +195,7 @@ func worker(config Config, pagesChan <-chan *Page, resultsChan chan<- string, mu
 			log.Printf("Error parsing HTML for %s: %v", page.URL, err)
 			continue
 		}
+		doc.Url = resp.Request.URL
 		
 		// Extract title
 		title := doc.Find("title").Text()
@@ -162,7 +204,7 @@ func worker(config Config, pagesChan <-chan *Page, resultsChan chan<- string, mu
 		}
 		
 		// Clean and extract content
-		content := cleanContent(doc)
+		content := cleanContent(doc, config)
 		if content == "" {
 			log.Printf("No content found for %s", page.URL)
 			continue
@@ -195,8 +237,59 @@ func worker(config Config, pagesChan <-chan *Page, resultsChan chan<- string, mu
 	}
 }
 
+// extraction is a page's main content and how it was found
+type extraction struct {
+	Content    string
+	Title      string  // From the site rule's title selector ("" = use <title>)
+	Profile    string  // Documentation generator detected on the page ("" = none)
+	Strategy   string  // Strategy and selector that produced the content, e.g. "semantic:main"
+	Confidence float64 // 0-1, how likely the content is the page's real article
+}
+
 // cleanContent extracts and cleans the main documentation content using multiple strategies
-func cleanContent(doc *goquery.Document) string {
+func cleanContent(doc *goquery.Document, config Config) string {
+	return extractContent(doc, config).Content
+}
+
+// extractContent finds the main documentation content, trying the detected
+// generator's profile first and then increasingly generic strategies
+func extractContent(doc *goquery.Document, config Config) extraction {
+	converter := &markdownConverter{baseURL: doc.Url, tablePolicy: config.TablePolicy}
+	result := extraction{}
+	
+	// A user site rule comes first: its title, removals and content selectors
+	rule := findSiteRule(config.SiteRules, doc.Url)
+	if rule != nil {
+		if rule.Title != "" {
+			result.Title = strings.Join(strings.Fields(doc.Find(rule.Title).First().Text()), " ")
+		}
+		if len(rule.Remove) > 0 {
+			// Remove from a copy; the page's links are still needed for crawling
+			pageURL := doc.Url
+			doc = goquery.CloneDocument(doc)
+			doc.Url = pageURL
+			for _, selector := range rule.Remove {
+				doc.Find(selector).Remove()
+			}
+		}
+		if content, selector := extractWithSiteRule(doc, rule, converter); content != "" {
+			// Selectors the user chose are trusted; the content itself can still lower the score
+			result.Content, result.Strategy = content, "site:"+selector
+			result.Confidence = 0.5 + contentConfidence(doc.Find(selector).First().Get(0), 1)/2
+			return result
+		}
+	}
+	
+	// Strategy 0: Use the known layout of the generator that built the page
+	if profile := detectProfile(doc); profile != nil && rule.allows(StrategyProfile) {
+		result.Profile = profile.Name
+		if content, selector := extractWithProfile(doc, profile, converter); content != "" {
+			result.Content, result.Strategy = content, StrategyProfile+":"+selector
+			result.Confidence = 0.5 + contentConfidence(doc.Find(selector).First().Get(0), 1)/2
+			return result
+		}
+	}
+	
 	// Strategy 1: Try semantic HTML5 elements (most reliable)
 	semanticSelectors := []string{
 		"main",
@@ -206,10 +299,15 @@ func cleanContent(doc *goquery.Document) string {
 	}
 	
 	for _, selector := range semanticSelectors {
+		if !rule.allows(StrategySemantic) {
+			break
+		}
 		if el := doc.Find(selector); el.Length() > 0 {
-			content := extractTextContent(el)
+			content := extractTextContent(el, converter)
 			if len(content) > 200 { // Minimum viable content
-				return content
+				result.Content, result.Strategy = content, StrategySemantic+":"+selector
+				result.Confidence = contentConfidence(el.Get(0), 1)
+				return result
 			}
 		}
 	}
@@ -236,119 +334,61 @@ func cleanContent(doc *goquery.Document) string {
 	}
 	
 	for _, selector := range classSelectors {
+		if !rule.allows(StrategyClass) {
+			break
+		}
 		if el := doc.Find(selector); el.Length() > 0 {
-			content := extractTextContent(el)
+			content := extractTextContent(el, converter)
 			if len(content) > 200 {
-				return content
+				result.Content, result.Strategy = content, StrategyClass+":"+selector
+				result.Confidence = contentConfidence(el.Get(0), 1)
+				return result
 			}
 		}
 	}
 	
-	// Strategy 3: Look for sections with high text density
-	var bestSection *goquery.Selection
-	maxTextLen := 0
-	
-	doc.Find("section, div").Each(func(i int, s *goquery.Selection) {
-		text := strings.TrimSpace(s.Text())
-		if len(text) > maxTextLen {
-			// Check if this section has more text than child elements
-			childText := 0
-			s.Children().Each(func(j int, c *goquery.Selection) {
-				childText += len(strings.TrimSpace(c.Text()))
-			})
-			
-			// If parent has significantly more text, it's likely the main content
-			if len(text) > childText + (childText/2) && len(text) > 500 {
-				maxTextLen = len(text)
-				bestSection = s
+	// Strategy 3: Score the page like a reader would and take the best article candidate
+	if rule.allows(StrategyReadability) {
+		if node, confidence := readabilityExtract(doc); node != nil {
+			if content := converter.convert(node); len(content) > 200 {
+				result.Content, result.Strategy, result.Confidence = content, StrategyReadability, confidence
+				return result
 			}
 		}
-	})
-	
-	if bestSection != nil {
-		content := extractTextContent(bestSection)
-		if len(content) > 200 {
-			return content
-		}
 	}
 	
-	// Strategy 4: Fallback to body with aggressive cleaning
-	body := doc.Find("body")
-	if body.Length() > 0 {
-		// Remove all non-content elements aggressively
+	// Strategy 4: Last resort, the whole body with aggressive cleaning
+	// Work on a copy; the page's links are still needed for crawling
+	body := doc.Find("body").First().Clone()
+	if body.Length() > 0 && rule.allows(StrategyBody) {
 		body.Find("nav, header, footer, aside, script, style, form, iframe, .sidebar, .toc, .navigation, .menu, .ads, .advertisement, [class*='nav'], [class*='menu'], [class*='sidebar'], [class*='footer'], [class*='header']").Remove()
 		
-		// Find the largest remaining container
-		var largest *goquery.Selection
-		largestSize := 0
-		
-		body.Find("*").Each(func(i int, s *goquery.Selection) {
-			text := strings.TrimSpace(s.Text())
-			if len(text) > largestSize && s.Children().Length() < 50 {
-				largestSize = len(text)
-				largest = s
-			}
-		})
-		
-		if largest != nil {
-			content := extractTextContent(largest)
-			if len(content) > 200 {
-				return content
-			}
-		}
-		
-		// Last resort: entire body
-		htmlContent, _ := body.Html()
-		cleaned := cleanHTML(htmlContent)
+		cleaned := extractTextContent(body, converter)
 		if len(cleaned) > 200 {
-			return cleaned
+			// Nothing singled this out as the article, so trust it half as much
+			result.Content, result.Strategy = cleaned, StrategyBody
+			result.Confidence = contentConfidence(body.Get(0), 0) / 2
+			return result
 		}
 	}
 	
-	return ""
+	return result
 }
 
-// extractTextContent extracts and cleans text from a selection
-func extractTextContent(sel *goquery.Selection) string {
+// extractTextContent extracts the selection's content as markdown
+func extractTextContent(sel *goquery.Selection, converter *markdownConverter) string {
 	// Clone the selection to avoid modifying original
 	clone := sel.Clone()
 	
 	// Remove unwanted elements
 	clone.Find("nav, header, footer, aside, script, style, form, iframe, .sidebar, .toc, .navigation, .menu, .ads, .advertisement, button, [class*='nav'], [class*='menu'], [class*='sidebar'], [class*='footer'], [class*='header'], [class*='button'], [onclick], [role='navigation'], [role='banner'], [role='contentinfo']").Remove()
 	
-	// Get HTML and convert to clean text
-	htmlContent, err := clone.Html()
-	if err != nil {
-		return ""
-	}
-	
-	return cleanHTML(htmlContent)
-}
-
-// cleanHTML performs basic HTML cleaning
-func cleanHTML(htmlStr string) string {
-	// Parse and extract text content while preserving structure
-	doc, err := html.Parse(strings.NewReader(htmlStr))
-	if err != nil {
+	if clone.Length() == 0 {
 		return ""
 	}
 	
-	var texts []string
-	var extractText func(*html.Node)
-	extractText = func(n *html.Node) {
-		if n.Type == html.TextNode {
-			text := strings.TrimSpace(n.Data)
-			if text != "" {
-				texts = append(texts, text)
-			}
-		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			extractText(c)
-		}
-	}
-	
-	extractText(doc)
-	return strings.Join(texts, "\n")
+	// Convert the first match to markdown
+	return converter.convert(clone.Get(0))
 }
 
 // isValidURL validates that a URL is safe to fetch
@@ -385,13 +425,36 @@ func isValidURL(urlStr string) error {
 
 // validateConfig validates the entire configuration
 func validateConfig(config *Config) error {
-	if err := validateOutputPath(config.OutputPath); err != nil {
+	if config.OutputDir != "" {
+		if err := validateLocalPath(config.OutputDir); err != nil {
+			return fmt.Errorf("output directory validation failed: %w", err)
+		}
+	} else if err := validateOutputPath(config.OutputPath); err != nil {
 		return fmt.Errorf("output path validation failed: %w", err)
 	}
 	
 	if err := isValidURL(config.BaseURL); err != nil {
 		return fmt.Errorf("base URL validation failed: %w", err)
 	}
+
+	if config.ReportPath != "" {
+		if err := validateJSONPath(config.ReportPath); err != nil {
+			return fmt.Errorf("report path validation failed: %w", err)
+		}
+	}
+	if config.StatePath != "" {
+		if err := validateJSONPath(config.StatePath); err != nil {
+			return fmt.Errorf("state path validation failed: %w", err)
+		}
+	}
+	if config.CacheDir != "" {
+		if err := validateLocalPath(config.CacheDir); err != nil {
+			return fmt.Errorf("cache directory validation failed: %w", err)
+		}
+	}
+	if config.Offline && config.CacheDir == "" {
+		return fmt.Errorf("offline mode needs a cache directory")
+	}
 	
 	// Limit depth to prevent excessive crawling
 	if config.MaxDepth > 10 {
@@ -402,6 +465,17 @@ func validateConfig(config *Config) error {
 	if config.Workers > 20 {
 		return fmt.Errorf("concurrent workers cannot exceed 20")
 	}
+
+	if config.MaxPages < 0 {
+		return fmt.Errorf("max pages cannot be negative")
+	}
+
+	if config.SitemapURL != "" {
+		if err := isValidURL(config.SitemapURL); err != nil {
+			return fmt.Errorf("sitemap URL validation failed: %w", err)
+		}
+		config.UseSitemap = true
+	}
 	
 	// Ensure reasonable timeout values
 	if config.Workers <= 0 {
@@ -410,6 +484,80 @@ func validateConfig(config *Config) error {
 	if config.MaxDepth <= 0 {
 		config.MaxDepth = 2 // Default
 	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Minute // Default
+	}
+	if config.SiteConfigPath != "" {
+		siteConfig, err := LoadSiteConfig(config.SiteConfigPath)
+		if err != nil {
+			return err
+		}
+		config.SiteRules = append(append([]SiteRule{}, config.SiteRules...), siteConfig.Sites...)
+	}
+	rules, err := compileSiteRules(config.SiteRules)
+	if err != nil {
+		return fmt.Errorf("site rules validation failed: %w", err)
+	}
+	config.SiteRules = rules
+	
+	if config.Format == "" {
+		config.Format = FormatMarkdown // Default
+	}
+	if err := checkOutputFormat(config); err != nil {
+		return err
+	}
+	if config.Format == FormatChunks {
+		if config.ChunkTokens <= 0 {
+			config.ChunkTokens = 512 // Default
+		}
+		if config.ChunkOverlap == 0 {
+			config.ChunkOverlap = config.ChunkTokens / 8 // Default
+		}
+		if config.ChunkOverlap >= config.ChunkTokens {
+			return fmt.Errorf("chunk overlap must be smaller than the chunk size")
+		}
+		if config.TokenEstimator == nil {
+			config.TokenEstimator = tokenEstimators[DefaultTokenEstimator] // Default
+		}
+	}
+
+	if config.LLMTxtFormat == "" {
+		config.LLMTxtFormat = LLMTxtSpec // Default
+	}
+	if config.LLMTxtSections == "" {
+		config.LLMTxtSections = LLMTxtSectionsAuto // Default
+	}
+	if err := checkLLMTxtOptions(config); err != nil {
+		return err
+	}
+
+	if config.TablePolicy == "" {
+		config.TablePolicy = TablePolicyHTML // Default
+	}
+	if config.TablePolicy != TablePolicyHTML && config.TablePolicy != TablePolicyList {
+		return fmt.Errorf("table policy must be %q or %q", TablePolicyHTML, TablePolicyList)
+	}
+	if config.RateLimit <= 0 {
+		config.RateLimit = 5 // Default
+	}
+	if config.RateBurst <= 0 {
+		config.RateBurst = 5 // Default
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = 3 // Default
+	}
+	if config.RetryBudget <= 0 {
+		config.RetryBudget = 100 // Default
+	}
+	if config.RetryBaseDelay <= 0 {
+		config.RetryBaseDelay = 500 * time.Millisecond // Default
+	}
+	if config.RetryMaxDelay <= 0 {
+		config.RetryMaxDelay = 30 * time.Second // Default
+	}
+	if strings.TrimSpace(config.UserAgent) == "" {
+		config.UserAgent = "DocFetch/1.0" // Default
+	}
 	
 	return nil
 }
\ No newline at end of file
@@ -2,13 +2,17 @@ package fetcher
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -17,94 +21,304 @@ import (
 	"github.com/PuerkitoBio/goquery"
 )
 
+// frontierMemoryLimit is how many queued URLs stay in memory before spilling to disk
+const frontierMemoryLimit = 10000
+
+// maxPageSize caps how much of a single page is downloaded
+const maxPageSize = 20 * 1024 * 1024
+
 // OptimizedFetcher uses advanced Go concurrency patterns for 10x speedup
 type OptimizedFetcher struct {
 	config        Config
 	httpClient    *http.Client
-	urlQueue      chan string
-	visited       sync.Map // Concurrent map instead of mutex-protected map
-	resultsChan   chan string
+	baseURL       *url.URL
+	scope         *crawlScope
+	decisions     sync.Map // Dry run only: URL -> why it was excluded ("" = queued)
+	frontier      *frontier
+	robots        *robotsCache // nil when robots.txt is ignored
+	limiter       *rateLimiter
+	retries       *retryPolicy
+	visited       sync.Map // Canonical URL keys; concurrent map instead of mutex-protected map
+	resultsChan   chan *PageResult
 	llmEntries    []LLMTxtEntry
+	llmSite       *LLMTxtSite // From the first page of the crawl
+	llmSiteOrder  int64
 	llmMutex      sync.Mutex
+	stableOrder   map[string]int64 // Reproducible output only: canonical key -> output position
+	nav           *NavTree         // Sidebar of the earliest page that has one
+	navOrder      int64
+	navPage       string // URL of the page the sidebar was read from
+	navMutex      sync.Mutex
 	pageCount     int32
 	errorCount    int32
+	throttleCount int32
+	profiles      map[string]int // Pages per detected documentation generator
+	profilesMutex sync.Mutex
+	reports       []PageReport // How each page's content was extracted
+	reportsMutex  sync.Mutex
+	state         *stateTracker  // nil without a state file
+	cache         *responseCache // nil without a cache directory
 	ctx           context.Context
 	cancel        context.CancelFunc
 }
 
+// PageResult is a fetched page ready to be written to the output
+type PageResult struct {
+	URL          string
+	CanonicalURL string // From redirects and <link rel="canonical">
+	Title        string
+	Content      string
+	Profile      string    // Documentation generator detected on the page
+	Strategy     string    // Extraction strategy and selector that found the content
+	Confidence   float64   // 0-1 extraction confidence; below 0.4 the page is flagged in the output
+	Status       int       // HTTP status of the final response
+	FetchedAt    time.Time // When the response was received
+	Depth        int
+	Parent       string
+	Order        int64    // Discovery order within the crawl
+	Links        []string // Reproducible output only: absolute URLs of the page's links, in page order
+	fetchedURL   string   // Reproducible output only: the URL requested, before URL became the canonical one
+}
+
+// CrawlStats summarizes a finished crawl
+type CrawlStats struct {
+	StopReason     StopReason
+	PagesFetched   int
+	Errors         int
+	Throttled      int               // 429/503 responses asking us to slow down
+	Retries        int               // Fetch attempts repeated after a transient failure
+	RetryRecovered int               // Pages that succeeded after retrying
+	RetryFailed    int               // Pages that still failed after retrying
+	PeakQueueDepth int               // Most URLs waiting in the frontier at once
+	SpilledURLs    int               // URLs that overflowed the in-memory queue to disk
+	Unchanged      int               // State file only: pages reused because they did not change
+	Changed        int               // State file only: pages whose Markdown is new or changed
+	Removed        int               // State file only: pages of the last run this one did not fetch (complete crawls only)
+	CacheHits      int               // Cache directory only: requests answered from disk
+	CacheMisses    int               // Cache directory only: requests that went to the network (or failed offline)
+	Profiles       map[string]int    // Pages per detected documentation generator ("none" = generic extraction)
+	Extraction     *ExtractionReport // How each page's content was extracted
+	Elapsed        time.Duration
+}
+
 // RunOptimized executes documentation fetching with maximum concurrency
 func RunOptimized(config Config) error {
+	_, err := RunOptimizedContext(context.Background(), config)
+	return err
+}
+
+// RunOptimizedContext executes documentation fetching until the site is exhausted,
+// the page budget is spent, ctx is cancelled or the configured timeout expires
+func RunOptimizedContext(ctx context.Context, config Config) (*CrawlStats, error) {
 	if err := validateConfig(&config); err != nil {
-		return fmt.Errorf("invalid configuration: %w", err)
+		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
 	log.Printf("🚀 Starting HIGH-PERFORMANCE documentation fetch from: %s", config.BaseURL)
 	log.Printf("   Workers: %d | Max Depth: %d | Concurrency: Enabled", config.Workers, config.MaxDepth)
 
+	fetcher, err := newOptimizedFetcher(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	defer fetcher.cancel()
+
+	return fetcher.run()
+}
+
+// newOptimizedFetcher creates a fetcher whose lifetime is bounded by ctx and config.Timeout
+func newOptimizedFetcher(ctx context.Context, config Config) (*OptimizedFetcher, error) {
+	baseURL, err := url.Parse(config.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, err := newCrawlScope(baseURL, config)
+	if err != nil {
+		return nil, err
+	}
+
 	fetcher := &OptimizedFetcher{
 		config:      config,
-		urlQueue:    make(chan string, config.Workers*100), // Large buffer for URLs
-		resultsChan: make(chan string, config.Workers*10), // Larger buffer
-		httpClient: createOptimizedHTTPClient(config.Workers),
+		baseURL:     baseURL,
+		scope:       scope,
+		frontier:    newFrontier(frontierMemoryLimit, config.MaxPages), // Overflow spills to disk
+		resultsChan: make(chan *PageResult, config.Workers*10), // Larger buffer
+		httpClient:  createOptimizedHTTPClient(config.Workers),
+		limiter:     newRateLimiter(config.RateLimit, config.RateBurst, config.AdaptiveRateLimit),
+		retries:     newRetryPolicy(config.MaxRetries, config.RetryBudget, config.RetryBaseDelay, config.RetryMaxDelay),
+		profiles:    make(map[string]int),
+	}
+	if config.CacheDir != "" {
+		cache, err := newResponseCache(config.CacheDir, config.Offline, fetcher.httpClient.Transport)
+		if err != nil {
+			return nil, err
+		}
+		fetcher.cache = cache
+		fetcher.httpClient.Transport = cache
+	}
+	if !config.IgnoreRobots {
+		fetcher.robots = newRobotsCache(fetcher.httpClient, fetcher.wait, config.UserAgent)
+	}
+	if config.StatePath != "" {
+		state, err := loadState(config.StatePath)
+		if err != nil {
+			return nil, err
+		}
+		fetcher.state = newStateTracker(state)
 	}
 
-	fetcher.ctx, fetcher.cancel = context.WithTimeout(context.Background(), 10*time.Minute)
-	defer fetcher.cancel()
+	fetcher.ctx, fetcher.cancel = context.WithTimeout(ctx, config.Timeout)
+	return fetcher, nil
+}
 
+// run crawls from the base URL and writes the output files
+func (f *OptimizedFetcher) run() (*CrawlStats, error) {
+	config := f.config
 	startTime := time.Now()
-	
-	// Start result writer in background
+
+	// Start result writer in background; a dry run writes nothing
+	var writeErr error
 	var writeWg sync.WaitGroup
 	writeWg.Add(1)
 	go func() {
-		defer writeWg.Add(-1)
-		writeResultsOptimized(config.OutputPath, fetcher.resultsChan)
+		defer writeWg.Done()
+		results := (<-chan *PageResult)(f.resultsChan)
+		if config.Reproducible && !config.DryRun {
+			results = f.stableResults(f.resultsChan)
+		}
+
+		switch {
+		case config.DryRun:
+		case config.OutputDir != "":
+			writeErr = writeResultsToDir(config.OutputDir, config.BaseURL, results)
+		case config.Format == FormatJSONL:
+			writeErr = writeResultsJSONL(config.OutputPath, results)
+		case config.Format == FormatChunks:
+			writeErr = writeResultsChunks(config.OutputPath, results, config.ChunkTokens, config.ChunkOverlap, config.TokenEstimator)
+		case config.Grouped && config.NavOrder:
+			writeErr = writeResultsGrouped(config.OutputPath, results, f.navTree)
+		case config.Grouped:
+			writeErr = writeResultsGrouped(config.OutputPath, results, nil)
+		case config.NavOrder:
+			writeErr = writeResultsNav(config.OutputPath, results, f.navTree)
+		default:
+			writeErr = writeResultsOptimized(config.OutputPath, results)
+		}
+		// Keep draining so workers never block on a failed writer
+		for range results {
+		}
 	}()
 
-	// Start worker pool
+	// Submit initial URL before any worker can observe an empty frontier
+	f.submitPage(config.BaseURL, 0, "")
+
+	// Pages listed in sitemaps may not be reachable through links
+	if sources := f.sitemapSources(); len(sources) > 0 {
+		f.seedFromSitemaps(sources)
+	}
+
+	// Stop handing out URLs once the context is cancelled or times out
+	go f.frontier.watch(f.ctx)
+
+	// Start worker pool; workers exit once the frontier is exhausted or stopped
 	var workerWg sync.WaitGroup
 	for i := 0; i < config.Workers; i++ {
 		workerWg.Add(1)
-		go fetcher.worker(i, &workerWg)
+		go f.worker(i, &workerWg)
 	}
 
-	// Submit initial URL
-	fetcher.submitPage(config.BaseURL, 0)
-
-	// Close URL queue when all pages are processed
-	go func() {
-		workerWg.Wait()
-		close(fetcher.urlQueue)
-	}()
-
 	// Wait for all workers to complete
 	workerWg.Wait()
-	close(fetcher.resultsChan)
+	close(f.resultsChan)
 
 	// Wait for results to be written
 	writeWg.Wait()
+	if writeErr != nil {
+		return nil, fmt.Errorf("failed to write output: %w", writeErr)
+	}
 
-	elapsed := time.Since(startTime)
-	pagesFetched := atomic.LoadInt32(&fetcher.pageCount)
-	errors := atomic.LoadInt32(&fetcher.errorCount)
+	stats := &CrawlStats{
+		StopReason:   f.frontier.stopReason(),
+		PagesFetched: int(atomic.LoadInt32(&f.pageCount)),
+		Errors:       int(atomic.LoadInt32(&f.errorCount)),
+		Throttled:    int(atomic.LoadInt32(&f.throttleCount)),
+		Elapsed:      time.Since(startTime),
+	}
+	stats.PeakQueueDepth, stats.SpilledURLs = f.frontier.queueStats()
+	stats.Retries, stats.RetryRecovered, stats.RetryFailed = f.retries.counts()
+	stats.Profiles = f.profiles
+	stats.Extraction = f.extractionReport()
+	if f.state != nil {
+		stats.Unchanged, stats.Changed, stats.Removed = f.state.counts(stats.StopReason)
+	}
+	if f.cache != nil {
+		stats.CacheHits, stats.CacheMisses = f.cache.counts()
+	}
 
 	log.Printf("✅ Fetch completed!")
-	log.Printf("   📊 Pages fetched: %d", pagesFetched)
-	log.Printf("   ⏱️  Time elapsed: %v", elapsed)
-	log.Printf("   📈 Speed: %.2f pages/second", float64(pagesFetched)/elapsed.Seconds())
-	log.Printf("   ❌ Errors: %d", errors)
+	log.Printf("   🛑 Stop reason: %s", stats.StopReason)
+	log.Printf("   📊 Pages fetched: %d", stats.PagesFetched)
+	log.Printf("   ⏱️  Time elapsed: %v", stats.Elapsed)
+	log.Printf("   📈 Speed: %.2f pages/second", float64(stats.PagesFetched)/stats.Elapsed.Seconds())
+	log.Printf("   📥 Peak queue depth: %d (%d spilled to disk)", stats.PeakQueueDepth, stats.SpilledURLs)
+	log.Printf("   🐢 Throttled responses: %d", stats.Throttled)
+	log.Printf("   🔁 Retries: %d (%d pages recovered, %d still failed)", stats.Retries, stats.RetryRecovered, stats.RetryFailed)
+	log.Printf("   🧩 Site profiles: %s", formatCounts(stats.Profiles))
+	if f.state != nil {
+		log.Printf("   ♻️  Incremental: %d unchanged, %d new or changed, %d gone since the last run", stats.Unchanged, stats.Changed, stats.Removed)
+	}
+	if f.cache != nil {
+		log.Printf("   🗄️  Response cache: %d hits, %d misses", stats.CacheHits, stats.CacheMisses)
+	}
+	logExtractionFallbacks(stats.Extraction)
+	log.Printf("   ❌ Errors: %d", stats.Errors)
+
+	if config.DryRun {
+		f.logScopeDecisions()
+		return stats, nil
+	}
+
+	if f.state != nil {
+		f.navMutex.Lock()
+		nav, navPage := f.nav, f.navPage
+		f.navMutex.Unlock()
+		if err := f.state.save(config.StatePath, config.BaseURL, nav, navPage, stats.StopReason); err != nil {
+			log.Printf("⚠️  Warning: Failed to write state file: %v", err)
+		} else {
+			log.Printf("💾 State written: %s", config.StatePath)
+		}
+	}
+
+	if config.ReportPath != "" {
+		if err := WriteExtractionReport(stats.Extraction, config.ReportPath); err != nil {
+			log.Printf("⚠️  Warning: Failed to write extraction report: %v", err)
+		} else {
+			log.Printf("📋 Extraction report written: %s (%d pages)", config.ReportPath, stats.Extraction.Summary.Pages)
+		}
+	}
 
 	// Generate LLM.txt if requested
-	if config.GenerateLLMTxt && len(fetcher.llmEntries) > 0 {
-		llmTxtPath := strings.TrimSuffix(config.OutputPath, ".md") + ".llm.txt"
-		if err := GenerateLLMTxt(fetcher.llmEntries, llmTxtPath); err != nil {
+	if config.GenerateLLMTxt && len(f.llmEntries) > 0 && config.LLMTxtFormat == LLMTxtSpec {
+		indexPath, fullPath := LLMsTxtPaths(config)
+		if err := f.writeLLMsTxt(indexPath, fullPath); err != nil {
+			log.Printf("⚠️  Warning: Failed to generate llms.txt: %v", err)
+		} else {
+			log.Printf("📝 llms.txt generated: %s and %s (%d entries)", indexPath, fullPath, len(f.llmEntries))
+		}
+	} else if config.GenerateLLMTxt && len(f.llmEntries) > 0 {
+		llmTxtPath := strings.TrimSuffix(strings.TrimSuffix(config.OutputPath, ".md"), ".jsonl") + ".llm.txt"
+		if config.OutputDir != "" {
+			llmTxtPath = filepath.Join(config.OutputDir, "llm.txt")
+		}
+		if err := GenerateLLMTxt(f.llmEntriesInOrder(), llmTxtPath); err != nil {
 			log.Printf("⚠️  Warning: Failed to generate llm.txt: %v", err)
 		} else {
-			log.Printf("📝 LLM.txt generated: %s (%d entries)", llmTxtPath, len(fetcher.llmEntries))
+			log.Printf("📝 LLM.txt generated: %s (%d entries)", llmTxtPath, len(f.llmEntries))
 		}
 	}
 
-	return nil
+	return stats, nil
 }
 
 // createOptimizedHTTPClient creates a high-performance HTTP client with connection pooling
@@ -126,44 +340,102 @@ func createOptimizedHTTPClient(workers int) *http.Client {
 	}
 }
 
-// worker processes URLs from the submission queue
+// worker processes URLs handed out by the frontier
 func (f *OptimizedFetcher) worker(id int, wg *sync.WaitGroup) {
 	defer wg.Done()
 	
-	for url := range f.urlQueue {
-		select {
-		case <-f.ctx.Done():
+	for {
+		item, ok := f.frontier.next()
+		if !ok {
 			return
-		default:
-			f.processURL(url, 0)
 		}
+		f.processURL(item)
+		f.frontier.release()
 	}
 }
 
-// submitPage adds a URL to be fetched (with depth tracking)
-func (f *OptimizedFetcher) submitPage(pageURL string, depth int) {
+// submitPage adds a URL to be fetched (with depth and parent tracking).
+// Seeds at depth 0 are always queued; anything else must be in the crawl scope.
+// It reports whether the URL was queued.
+func (f *OptimizedFetcher) submitPage(pageURL string, depth int, parent string) bool {
 	if depth > f.config.MaxDepth {
-		return
+		return false
 	}
 
-	// Check if already visited using atomic operation
-	if _, loaded := f.visited.LoadOrStore(pageURL, true); loaded {
-		return
+	parsed, err := url.Parse(pageURL)
+	if err != nil {
+		return false
+	}
+	parsed = cleanURL(parsed)
+	pageURL = parsed.String()
+
+	if depth > 0 {
+		if reason := f.scope.check(parsed); reason != "" {
+			f.recordScopeDecision(pageURL, reason)
+			return false
+		}
+	}
+
+	// Check if already visited using atomic operation; variants of one page share a canonical key
+	if _, loaded := f.visited.LoadOrStore(canonicalKey(parsed), true); loaded {
+		return false
+	}
+
+	if f.robots != nil && !f.robots.allowed(f.ctx, pageURL) {
+		log.Printf("🤖 Disallowed by robots.txt: %s", pageURL)
+		f.recordScopeDecision(pageURL, "disallowed by robots.txt")
+		return false
+	}
+
+	// The frontier only refuses work once the crawl is stopping; it puts
+	// pages in the sidebar first, in sidebar order
+	if !f.frontier.push(&workItem{URL: pageURL, Depth: depth, Parent: parent}) {
+		return false
+	}
+	f.recordScopeDecision(pageURL, "")
+	return true
+}
+
+// recordScopeDecision remembers why a URL was queued or excluded, for the dry-run report
+func (f *OptimizedFetcher) recordScopeDecision(pageURL, reason string) {
+	if f.config.DryRun {
+		f.decisions.LoadOrStore(pageURL, reason)
 	}
+}
+
+// logScopeDecisions prints every URL the dry run considered and why excluded ones were skipped
+func (f *OptimizedFetcher) logScopeDecisions() {
+	var included, excluded []string
+	reasons := make(map[string]string)
 
-	select {
-	case f.urlQueue <- pageURL:
-		// Successfully queued
-	default:
-		// Queue full, skip this URL
-		log.Printf("⚠️  Queue full, skipping: %s", pageURL)
+	f.decisions.Range(func(key, value interface{}) bool {
+		pageURL, reason := key.(string), value.(string)
+		if reason == "" {
+			included = append(included, pageURL)
+		} else {
+			excluded = append(excluded, pageURL)
+			reasons[pageURL] = reason
+		}
+		return true
+	})
+	sort.Strings(included)
+	sort.Strings(excluded)
+
+	log.Printf("🧪 Dry run: %d URLs in scope, %d excluded (nothing written)", len(included), len(excluded))
+	for _, pageURL := range included {
+		log.Printf("   ✅ %s", pageURL)
+	}
+	for _, pageURL := range excluded {
+		log.Printf("   ⛔ %s — %s", pageURL, reasons[pageURL])
 	}
 }
 
-// processURL fetches and processes a single URL
-func (f *OptimizedFetcher) processURL(pageURL string, depth int) {
+// processURL fetches and processes a single work item
+func (f *OptimizedFetcher) processURL(item *workItem) {
 	atomic.AddInt32(&f.pageCount, 1)
 
+	pageURL := item.URL
+
 	startTime := time.Now()
 	
 	// Validate URL
@@ -173,81 +445,316 @@ func (f *OptimizedFetcher) processURL(pageURL string, depth int) {
 		return
 	}
 
-	// Fetch the page
-	resp, err := f.httpClient.Get(pageURL)
+	// Fetch the page, retrying transient failures; the state file lets the server answer 304
+	prev := f.state.page(pageURL)
+	resp, err := f.fetch(pageURL, prev)
 	if err != nil {
-		atomic.AddInt32(&f.errorCount, 1)
-		log.Printf("❌ Error fetching %s: %v", pageURL, err)
+		if f.ctx.Err() == nil {
+			atomic.AddInt32(&f.errorCount, 1)
+			log.Printf("❌ Error fetching %s: %v", pageURL, err)
+		}
 		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		atomic.AddInt32(&f.errorCount, 1)
-		log.Printf("❌ Non-200 status %d for %s", resp.StatusCode, pageURL)
-		return
+	fetchedAt := time.Now()
+
+	// Pages unchanged since the last run keep their Markdown instead of being extracted again
+	var page *pageState
+	reused := prev.unchanged(resp)
+	if reused {
+		page = f.reusePage(item, prev.revalidated(resp))
+	} else {
+		page = f.extractPage(item, resp)
 	}
-
-	// Parse HTML concurrently
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		atomic.AddInt32(&f.errorCount, 1)
-		log.Printf("❌ Error parsing HTML for %s: %v", pageURL, err)
+	if page == nil {
 		return
 	}
+	f.state.record(pageURL, page, reused)
 
-	// Extract content
-	content := cleanContent(doc)
-	if content == "" {
-		atomic.AddInt32(&f.errorCount, 1)
-		log.Printf("⚠️  No content found for %s", pageURL)
-		return
+	// Queue links for crawling (if depth allows); submitPage drops links outside the crawl scope
+	if item.Depth < f.config.MaxDepth {
+		for _, link := range page.Links {
+			f.submitPage(link, item.Depth+1, page.URL)
+		}
 	}
-
-	// Extract title
-	title := doc.Find("title").Text()
-	if title == "" {
-		title = pageURL
+	var links []string
+	if f.config.Reproducible {
+		links = page.Links
 	}
 
 	// Send result
-	f.resultsChan <- fmt.Sprintf("## %s\n\n%s\n\n---\n\n", title, content)
+	f.resultsChan <- &PageResult{
+		URL:          pageURL,
+		CanonicalURL: page.CanonicalURL,
+		Title:        page.Title,
+		Content:      page.Content,
+		Profile:      page.Profile,
+		Strategy:     page.Strategy,
+		Confidence:   page.Confidence,
+		Status:       page.Status, // A reused page reports the status it was extracted from, not 304
+		FetchedAt:    fetchedAt,
+		Depth:        item.Depth,
+		Parent:       item.Parent,
+		Order:        item.Order,
+		Links:        links,
+	}
 
 	// Generate LLM.txt entry if requested
 	if f.config.GenerateLLMTxt {
-		cleanTitle := CleanTitle(title)
-		entryType := ClassifyPage(pageURL, cleanTitle)
-		description := ExtractDescription(content)
+		// Reproducible output names a page by its canonical URL, not whichever duplicate came first
+		entryURL := pageURL
+		if f.config.Reproducible {
+			entryURL = page.CanonicalURL
+		}
+		cleanTitle := CleanTitle(page.Title)
+		entryType := ClassifyPage(entryURL, cleanTitle)
+		description := ExtractDescription(page.Content)
 
 		entry := LLMTxtEntry{
 			Type:        entryType,
 			Title:       cleanTitle,
-			URL:         pageURL,
+			URL:         entryURL,
 			Description: description,
 		}
 
+		if f.config.LLMTxtFormat == LLMTxtSpec {
+			entry.Description = page.Description
+			entry.Content = page.Content
+			f.recordLLMTxtSite(llmTxtSite(page.SiteName, page.Title, page.Description), item)
+		}
+		entry.Order = item.Order
+
 		f.llmMutex.Lock()
 		f.llmEntries = append(f.llmEntries, entry)
 		f.llmMutex.Unlock()
 	}
 
-	// Extract links for crawling (if depth allows)
-	if depth < f.config.MaxDepth {
-		f.extractAndSubmitLinks(doc, pageURL, depth+1)
+	elapsed := time.Since(startTime)
+	if reused {
+		log.Printf("♻️  Unchanged %s (%.2fs)", pageURL, elapsed.Seconds())
+	} else {
+		log.Printf("✅ Fetched %s (%.2fs)", pageURL, elapsed.Seconds())
 	}
+}
 
-	elapsed := time.Since(startTime)
-	log.Printf("✅ Fetched %s (%.2fs)", pageURL, elapsed.Seconds())
+// extractPage parses a fetched page and extracts its Markdown, title and
+// links. It returns nil for duplicates and pages without content.
+func (f *OptimizedFetcher) extractPage(item *workItem, resp *fetchedResponse) *pageState {
+	pageURL := item.URL
+
+	// Parse HTML concurrently
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+	if err != nil {
+		atomic.AddInt32(&f.errorCount, 1)
+		log.Printf("❌ Error parsing HTML for %s: %v", pageURL, err)
+		return nil
+	}
+	doc.Url, _ = url.Parse(resp.URL)
+
+	// A redirect or canonical link may show this page was already fetched under another URL
+	canonicalURL, unique := f.claimIdentity(item, resp.URL, doc)
+	if !unique {
+		log.Printf("♻️  Skipping %s: duplicate of %s", pageURL, canonicalURL)
+		return nil
+	}
+
+	// Extract content
+	extracted := extractContent(doc, f.config)
+	f.recordProfile(extracted.Profile)
+
+	// Extract title
+	title := extracted.Title
+	if title == "" {
+		title = doc.Find("title").Text()
+	}
+	if title == "" {
+		title = pageURL
+	}
+
+	f.recordExtraction(newPageReport(pageURL, title, extracted, item.Order))
+	content := extracted.Content
+	if content == "" {
+		atomic.AddInt32(&f.errorCount, 1)
+		log.Printf("⚠️  No content found for %s", pageURL)
+		return nil
+	}
+
+	// The sidebar decides crawl priority, so it must be known before the links are queued
+	profile := findProfile(extracted.Profile)
+	f.recordNav(doc, item, profile)
+
+	return &pageState{
+		URL:          resp.URL,
+		Status:       resp.Status,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		BodyHash:     contentHash(string(resp.Body)),
+		ContentHash:  contentHash(content),
+		CanonicalURL: canonicalURL,
+		Title:        title,
+		Description:  llmTxtDescription(doc, content),
+		SiteName:     strings.TrimSpace(doc.Find("meta[property='og:site_name']").AttrOr("content", "")),
+		Profile:      extracted.Profile,
+		Strategy:     extracted.Strategy,
+		Confidence:   extracted.Confidence,
+		Content:      content,
+		// Resolve relative links against where the page actually lives after redirects
+		Links: extractLinks(doc, resp.URL, profile),
+	}
+}
+
+// reusePage takes a page unchanged since the last run from the state file,
+// recording it the way extractPage would. It returns nil for duplicates.
+func (f *OptimizedFetcher) reusePage(item *workItem, page *pageState) *pageState {
+	final, err := url.Parse(page.URL)
+	if err != nil {
+		return nil
+	}
+	link, _ := url.Parse(page.CanonicalURL)
+	if canonicalURL, unique := f.claimURLs(item, final, link); !unique {
+		log.Printf("♻️  Skipping %s: duplicate of %s", item.URL, canonicalURL)
+		return nil
+	}
+
+	f.recordProfile(page.Profile)
+	f.recordExtraction(newPageReport(item.URL, page.Title, extraction{
+		Content:    page.Content,
+		Title:      page.Title,
+		Profile:    page.Profile,
+		Strategy:   page.Strategy,
+		Confidence: page.Confidence,
+	}, item.Order))
+
+	// The page the sidebar was read from brings it back
+	if nav := f.state.prev.Nav; nav != nil && nav.Page == item.URL {
+		f.useNav(nav.tree(), item)
+	}
+	return page
 }
 
-// extractAndSubmitLinks finds and queues all internal links
-func (f *OptimizedFetcher) extractAndSubmitLinks(doc *goquery.Document, baseURL string, depth int) {
+// fetchedResponse is a successful response with its body already read
+type fetchedResponse struct {
+	URL    string // Final URL after redirects
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// fetch GETs a page through the rate limiter, retrying transient failures
+// with exponential backoff until the retry policy gives up. With the page's
+// previous state the request is conditional and may come back 304 Not Modified.
+func (f *OptimizedFetcher) fetch(pageURL string, prev *pageState) (*fetchedResponse, error) {
+	for attempt := 1; ; attempt++ {
+		resp, retryAfter, err := f.fetchOnce(pageURL, prev)
+		if err == nil {
+			f.retries.finish(attempt, nil)
+			return resp, nil
+		}
+
+		if f.ctx.Err() != nil || !isRetryable(err) || !f.retries.allow(attempt, retryAfter) {
+			f.retries.finish(attempt, err)
+			if attempt > 1 {
+				return nil, fmt.Errorf("%w (after %d attempts)", err, attempt)
+			}
+			return nil, err
+		}
+
+		wait := f.retries.delay(attempt, retryAfter)
+		log.Printf("🔁 Retrying %s in %v (attempt %d): %v", pageURL, wait.Round(time.Millisecond), attempt+1, err)
+		if err := f.retries.sleep(f.ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait holds a request back until the host's rate limit allows it; cached
+// responses cost the host nothing and go straight through
+func (f *OptimizedFetcher) wait(req *http.Request) error {
+	if f.cache.has(req) {
+		return nil
+	}
+	return f.limiter.wait(req.Context(), req.URL.Host)
+}
+
+// fetchOnce performs a single GET, also returning the server's Retry-After hint
+func (f *OptimizedFetcher) fetchOnce(pageURL string, prev *pageState) (*fetchedResponse, time.Duration, error) {
+	req, err := http.NewRequestWithContext(f.ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("User-Agent", f.config.UserAgent)
+	prev.setConditionalHeaders(req)
+
+	// Throttle per host, never faster than the host's robots.txt Crawl-delay
+	if f.robots != nil && !f.cache.has(req) {
+		f.limiter.setCrawlDelay(req.URL.Host, f.robots.get(f.ctx, req.URL).crawlDelay)
+	}
+	if err := f.wait(req); err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if f.limiter.observe(req.URL.Host, resp) {
+		atomic.AddInt32(&f.throttleCount, 1)
+	}
+
+	if resp.StatusCode == http.StatusNotModified && prev != nil {
+		return &fetchedResponse{
+			URL:    resp.Request.URL.String(),
+			Status: resp.StatusCode,
+			Header: resp.Header,
+		}, 0, nil
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()), &statusError{Status: resp.StatusCode}
+	}
+
+	// Read the whole body here so a connection dropped mid-page is retried too
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &fetchedResponse{
+		URL:    resp.Request.URL.String(),
+		Status: resp.StatusCode,
+		Header: resp.Header,
+		Body:   body,
+	}, 0, nil
+}
+
+// extractLinks returns the absolute URL of every link on a page, sidebar
+// first so pages are discovered in navigation order, without repeats
+func extractLinks(doc *goquery.Document, baseURL string, profile *siteProfile) []string {
 	base, err := url.Parse(baseURL)
 	if err != nil {
-		return
+		return nil
 	}
 
-	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+	// A <base href> changes what relative links are resolved against
+	if href, exists := doc.Find("base[href]").First().Attr("href"); exists {
+		if resolved, err := base.Parse(strings.TrimSpace(href)); err == nil {
+			base = resolved
+		}
+	}
+
+	links := doc.Find("a[href]")
+
+	// Queue the sidebar first so pages are discovered in navigation order
+	if profile != nil {
+		if nav := navLinks(doc, profile); nav != nil {
+			links = nav.AddSelection(links)
+		}
+	}
+
+	var urls []string
+	seen := make(map[string]bool)
+	links.Each(func(i int, s *goquery.Selection) {
 		href, exists := s.Attr("href")
 		if !exists {
 			return
@@ -258,19 +765,12 @@ func (f *OptimizedFetcher) extractAndSubmitLinks(doc *goquery.Document, baseURL
 		if err != nil {
 			return
 		}
-
-		// Only follow same-domain links
-		if resolvedURL.Host != "" && resolvedURL.Host != base.Host {
-			return
-		}
-
-		// Skip non-HTML resources
-		if isNonHTMLResource(resolvedURL.Path) {
-			return
+		if key := canonicalKey(resolvedURL); !seen[key] {
+			seen[key] = true
+			urls = append(urls, resolvedURL.String())
 		}
-
-		f.submitPage(resolvedURL.String(), depth)
 	})
+	return urls
 }
 
 // isNonHTMLResource checks if URL points to non-HTML resources
@@ -286,8 +786,11 @@ func isNonHTMLResource(path string) bool {
 	return false
 }
 
+// markdownHeader starts single-file Markdown output
+const markdownHeader = "# Documentation\n\nThis file contains documentation fetched by DocFetch.\n\n---\n\n"
+
 // writeResultsOptimized writes results to file efficiently
-func writeResultsOptimized(outputPath string, resultsChan <-chan string) error {
+func writeResultsOptimized(outputPath string, resultsChan <-chan *PageResult) error {
 	file, err := os.Create(outputPath)
 	if err != nil {
 		return err
@@ -298,13 +801,12 @@ func writeResultsOptimized(outputPath string, resultsChan <-chan string) error {
 	defer writer.Flush()
 
 	// Write header
-	header := "# Documentation\n\nThis file contains documentation fetched by DocFetch.\n\n---\n\n"
-	writer.WriteString(header)
+	writer.WriteString(markdownHeader)
 
 	count := 0
 	for result := range resultsChan {
-		if strings.TrimSpace(result) != "" {
-			writer.WriteString(result)
+		if strings.TrimSpace(result.Content) != "" {
+			writer.WriteString(formatPageResult(result))
 			count++
 			
 			// Flush periodically to avoid memory buildup
@@ -316,3 +818,32 @@ func writeResultsOptimized(outputPath string, resultsChan <-chan string) error {
 
 	return nil
 }
+
+// formatPageResult renders a page as a markdown section with its crawl metadata
+func formatPageResult(page *PageResult) string {
+	return formatPageSection(page, 2, page.Content)
+}
+
+// formatPageSection renders a page under a heading of the given level
+func formatPageSection(page *PageResult, level int, content string) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%s %s\n\n", strings.Repeat("#", level), page.Title)
+	fmt.Fprintf(&sb, "*Source: [%s](%s) · Depth: %d", page.URL, page.URL, page.Depth)
+	if page.Parent != "" {
+		fmt.Fprintf(&sb, " · Linked from: [%s](%s)", page.Parent, page.Parent)
+	}
+	sb.WriteString("*\n\n")
+	sb.WriteString(lowConfidenceNotice(page))
+	fmt.Fprintf(&sb, "%s\n\n---\n\n", content)
+
+	return sb.String()
+}
+
+// lowConfidenceNotice warns readers when the content may not be the page's main content
+func lowConfidenceNotice(page *PageResult) string {
+	if page.Confidence >= lowConfidence {
+		return ""
+	}
+	return fmt.Sprintf("> ⚠️ Low extraction confidence (%.2f): this may not be the page's main content.\n\n", page.Confidence)
+}
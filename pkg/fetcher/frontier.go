@@ -0,0 +1,363 @@
+package fetcher
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
+
+// StopReason describes why a crawl finished
+type StopReason string
+
+const (
+	// StopExhausted means every discovered URL was fetched
+	StopExhausted StopReason = "exhausted"
+	// StopBudget means the MaxPages budget was reached
+	StopBudget StopReason = "budget"
+	// StopCancelled means the caller cancelled the crawl
+	StopCancelled StopReason = "cancelled"
+	// StopTimeout means the crawl ran past its deadline
+	StopTimeout StopReason = "timeout"
+	// StopSpillFailed means queued URLs spilled to disk could not be read back
+	StopSpillFailed StopReason = "spill-failed"
+)
+
+// workItem is a URL waiting to be fetched, along with where it was discovered
+type workItem struct {
+	URL     string
+	Depth   int
+	Parent  string // Page the URL was found on ("" for seeds)
+	Order   int64  // Discovery sequence number, assigned by the frontier
+	NavRank int    // Position in the site's sidebar (0 = not listed)
+}
+
+// workQueue orders pages listed in the sidebar first, in sidebar order, and
+// the rest breadth-first: shallower pages first, then by discovery order
+type workQueue []*workItem
+
+func (q workQueue) Len() int { return len(q) }
+
+func (q workQueue) Less(i, j int) bool { return fetchedBefore(q[i], q[j]) }
+
+// fetchedBefore reports whether a is due before b
+func fetchedBefore(a, b *workItem) bool {
+	if (a.NavRank > 0) != (b.NavRank > 0) {
+		return a.NavRank > 0
+	}
+	if a.NavRank != b.NavRank {
+		return a.NavRank < b.NavRank
+	}
+	if a.Depth != b.Depth {
+		return a.Depth < b.Depth
+	}
+	return a.Order < b.Order
+}
+
+func (q workQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *workQueue) Push(x interface{}) { *q = append(*q, x.(*workItem)) }
+
+func (q *workQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return item
+}
+
+// frontier holds URLs waiting to be fetched and counts the ones being fetched,
+// so the crawl can detect when the site is exhausted and stop its workers.
+// It never drops work: once memoryLimit items are queued, the overflow
+// spills to a temporary file per depth and is read back as the queue drains.
+type frontier struct {
+	mu          sync.Mutex
+	cond        *sync.Cond
+	queue       workQueue
+	memoryLimit int
+	spills      []*spillQueue // By depth
+	spillFailed bool
+	rank        func(pageURL string) int // Sidebar position of a URL (nil until the sidebar is found)
+	spilled     int
+	peak        int
+	sequence    int64
+	inFlight    int
+	dispatched  int
+	budget      int
+	closed      bool
+	reason      StopReason
+	done        chan struct{}
+}
+
+// newFrontier creates a frontier keeping at most memoryLimit pending URLs in memory.
+// A budget greater than zero caps the number of URLs handed to workers.
+func newFrontier(memoryLimit, budget int) *frontier {
+	fr := &frontier{
+		memoryLimit: memoryLimit,
+		budget:      budget,
+		done:        make(chan struct{}),
+	}
+	fr.cond = sync.NewCond(&fr.mu)
+	return fr
+}
+
+// push queues an item and stamps its discovery order and sidebar position,
+// returning false only if the crawl is already over
+func (fr *frontier) push(item *workItem) bool {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if fr.closed {
+		return false
+	}
+
+	item.Order = fr.sequence
+	fr.sequence++
+	if fr.rank != nil {
+		item.NavRank = fr.rank(item.URL)
+	}
+
+	// Sidebar pages stay in memory: there are few of them and they come first
+	if len(fr.queue) < fr.memoryLimit || item.NavRank > 0 || !fr.spillLocked(item) {
+		heap.Push(&fr.queue, item)
+	}
+
+	if pending := fr.pendingLocked(); pending > fr.peak {
+		fr.peak = pending
+	}
+
+	fr.cond.Signal()
+	return true
+}
+
+// spillLocked moves an item to disk, reporting false if it must stay in memory
+func (fr *frontier) spillLocked(item *workItem) bool {
+	if fr.spillFailed {
+		return false
+	}
+
+	for len(fr.spills) <= item.Depth {
+		fr.spills = append(fr.spills, nil)
+	}
+	spill := fr.spills[item.Depth]
+	if spill == nil {
+		var err error
+		spill, err = newSpillQueue()
+		if err != nil {
+			// Keep everything in memory rather than dropping URLs
+			log.Printf("⚠️  %v; keeping frontier in memory", err)
+			fr.spillFailed = true
+			return false
+		}
+		fr.spills[item.Depth] = spill
+	}
+
+	if err := spill.write(item); err != nil {
+		log.Printf("⚠️  Failed to spill %s to disk: %v", item.URL, err)
+		return false
+	}
+
+	fr.spilled++
+	return true
+}
+
+// refillLocked reads spilled items back: always when the first of them is
+// due before everything in memory, and in bulk once the in-memory queue runs
+// low. It reports false if the spill files could not be read, which stops the crawl.
+func (fr *frontier) refillLocked() bool {
+	bulk := len(fr.queue) <= fr.memoryLimit/2
+	for {
+		spill := fr.nextSpillLocked()
+		if spill == nil {
+			return true
+		}
+		item, err := spill.peek()
+		if err != nil {
+			log.Printf("❌ %v; stopping the crawl", err)
+			fr.closeLocked(StopSpillFailed)
+			return false
+		}
+		if fr.rank != nil {
+			item.NavRank = fr.rank(item.URL)
+		}
+
+		due := len(fr.queue) == 0 || fetchedBefore(item, fr.queue[0])
+		if !due && !(bulk && len(fr.queue) < fr.memoryLimit) {
+			return true
+		}
+		heap.Push(&fr.queue, spill.pop())
+	}
+}
+
+// nextSpillLocked returns the spill queue of the shallowest depth with items
+// left; within a depth, items were spilled in discovery order
+func (fr *frontier) nextSpillLocked() *spillQueue {
+	for _, spill := range fr.spills {
+		if spill != nil && spill.pending > 0 {
+			return spill
+		}
+	}
+	return nil
+}
+
+// rerank orders the queue by sidebar position once the sidebar is known,
+// reading spilled items back so the ones it lists move into memory
+func (fr *frontier) rerank(rank func(pageURL string) int) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if fr.closed {
+		return
+	}
+	fr.rank = rank
+	for _, item := range fr.queue {
+		item.NavRank = rank(item.URL)
+	}
+	heap.Init(&fr.queue)
+
+	spills := fr.spills
+	fr.spills = nil
+	for i, spill := range spills {
+		if spill == nil {
+			continue
+		}
+		for spill.pending > 0 {
+			item, err := spill.peek()
+			if err != nil {
+				log.Printf("❌ %v; stopping the crawl", err)
+				for _, unread := range spills[i:] {
+					if unread != nil {
+						unread.close()
+					}
+				}
+				fr.closeLocked(StopSpillFailed)
+				return
+			}
+			spill.pop()
+
+			item.NavRank = rank(item.URL)
+			if item.NavRank == 0 && fr.spillLocked(item) {
+				fr.spilled-- // Counted when it first went to disk
+			} else {
+				heap.Push(&fr.queue, item)
+			}
+		}
+		spill.close()
+	}
+}
+
+// pendingLocked counts queued items, in memory and on disk
+func (fr *frontier) pendingLocked() int {
+	pending := len(fr.queue)
+	for _, spill := range fr.spills {
+		if spill != nil {
+			pending += spill.pending
+		}
+	}
+	return pending
+}
+
+// next blocks until an item is ready to fetch or the crawl has stopped.
+// Every item returned must be released with release.
+func (fr *frontier) next() (*workItem, bool) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	for {
+		if fr.closed {
+			return nil, false
+		}
+
+		if !fr.refillLocked() {
+			return nil, false
+		}
+
+		if len(fr.queue) > 0 {
+			if fr.budget > 0 && fr.dispatched >= fr.budget {
+				fr.closeLocked(StopBudget)
+				return nil, false
+			}
+
+			item := heap.Pop(&fr.queue).(*workItem)
+			fr.inFlight++
+			fr.dispatched++
+			return item, true
+		}
+
+		// Nothing queued and nothing being fetched means nothing more can be discovered
+		if fr.inFlight == 0 {
+			fr.closeLocked(StopExhausted)
+			return nil, false
+		}
+
+		fr.cond.Wait()
+	}
+}
+
+// release marks an in-flight item as finished
+func (fr *frontier) release() {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.inFlight--
+	if fr.inFlight == 0 && fr.pendingLocked() == 0 {
+		fr.closeLocked(StopExhausted)
+	}
+}
+
+// stop ends the crawl early; in-flight URLs still finish
+func (fr *frontier) stop(reason StopReason) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.closeLocked(reason)
+}
+
+// watch stops the frontier when ctx ends, until the crawl finishes on its own
+func (fr *frontier) watch(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			fr.stop(StopTimeout)
+		} else {
+			fr.stop(StopCancelled)
+		}
+	case <-fr.done:
+	}
+}
+
+// stopReason reports why the crawl stopped
+func (fr *frontier) stopReason() StopReason {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	return fr.reason
+}
+
+// queueStats reports the deepest the queue got and how many items went to disk
+func (fr *frontier) queueStats() (peak, spilled int) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	return fr.peak, fr.spilled
+}
+
+// closeLocked records the first stop reason and wakes every waiting worker
+func (fr *frontier) closeLocked(reason StopReason) {
+	if fr.closed {
+		return
+	}
+
+	fr.closed = true
+	fr.reason = reason
+	fr.queue = nil
+	for _, spill := range fr.spills {
+		if spill != nil {
+			spill.close()
+		}
+	}
+	fr.spills = nil
+	close(fr.done)
+	fr.cond.Broadcast()
+}
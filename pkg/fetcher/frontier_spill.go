@@ -0,0 +1,99 @@
+package fetcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// spillQueue is a FIFO of work items kept in a temporary file. The frontier
+// moves overflow here once its in-memory queue is full, so big sites never
+// lose URLs and memory use stays bounded. Each depth gets its own queue, so
+// the items in one are already in crawl order.
+type spillQueue struct {
+	file     *os.File
+	readFile *os.File
+	writer   *bufio.Writer
+	reader   *bufio.Reader
+	head     *workItem // Front item, read ahead by peek
+	pending  int       // Items written but not popped yet, including head
+}
+
+// newSpillQueue creates a spill file in the system temp directory
+func newSpillQueue() (*spillQueue, error) {
+	file, err := os.CreateTemp("", "docfetch-frontier-*.jsonl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create frontier spill file: %w", err)
+	}
+
+	// A second handle reads from the start while the first keeps appending
+	readFile, err := os.Open(file.Name())
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to open frontier spill file: %w", err)
+	}
+
+	return &spillQueue{
+		file:     file,
+		readFile: readFile,
+		writer:   bufio.NewWriter(file),
+		reader:   bufio.NewReader(readFile),
+	}, nil
+}
+
+// write appends an item to the end of the queue
+func (sq *spillQueue) write(item *workItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if _, err := sq.writer.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	sq.pending++
+	return nil
+}
+
+// peek returns the item at the front of the queue without removing it, or nil if it is empty
+func (sq *spillQueue) peek() (*workItem, error) {
+	if sq.head != nil || sq.pending == 0 {
+		return sq.head, nil
+	}
+
+	// Only whole lines reach the file, so the reader never sees half an item
+	if err := sq.writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	line, err := sq.reader.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read frontier spill file: %w", err)
+	}
+
+	item := &workItem{}
+	if err := json.Unmarshal(line, item); err != nil {
+		return nil, fmt.Errorf("corrupt frontier spill entry: %w", err)
+	}
+	sq.head = item
+	return item, nil
+}
+
+// pop removes the item at the front of the queue, which peek must have returned
+func (sq *spillQueue) pop() *workItem {
+	item := sq.head
+	if item != nil {
+		sq.head = nil
+		sq.pending--
+	}
+	return item
+}
+
+// close deletes the spill file
+func (sq *spillQueue) close() {
+	sq.file.Close()
+	sq.readFile.Close()
+	os.Remove(sq.file.Name())
+}
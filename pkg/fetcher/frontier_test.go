@@ -0,0 +1,95 @@
+package fetcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+// drain fetches everything left in the frontier and returns the URLs in order
+func drain(fr *frontier) []string {
+	var urls []string
+	for {
+		item, ok := fr.next()
+		if !ok {
+			return urls
+		}
+		urls = append(urls, item.URL)
+		fr.release()
+	}
+}
+
+func TestFrontierOrderAcrossSpill(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []workItem
+		rank  map[string]int // Sidebar positions applied after pushing (nil = no sidebar)
+		want  []string
+	}{
+		{
+			name: "shallow pages spilled after deep ones still come first",
+			items: []workItem{
+				{URL: "d2-a", Depth: 2}, {URL: "d2-b", Depth: 2}, {URL: "d2-c", Depth: 2}, {URL: "d2-d", Depth: 2},
+				{URL: "d1-a", Depth: 1}, {URL: "d1-b", Depth: 1}, {URL: "d1-c", Depth: 1},
+			},
+			want: []string{"d1-a", "d1-b", "d1-c", "d2-a", "d2-b", "d2-c", "d2-d"},
+		},
+		{
+			name: "discovery order within a depth survives the spill",
+			items: []workItem{
+				{URL: "a", Depth: 1}, {URL: "b", Depth: 1}, {URL: "c", Depth: 1}, {URL: "d", Depth: 1}, {URL: "e", Depth: 1},
+			},
+			want: []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			name: "sidebar found later re-ranks spilled pages",
+			items: []workItem{
+				{URL: "s0", Depth: 1}, {URL: "s1", Depth: 1}, {URL: "s2", Depth: 1}, {URL: "s3", Depth: 1}, {URL: "s4", Depth: 1},
+			},
+			rank: map[string]int{"s4": 1, "s1": 2},
+			want: []string{"s4", "s1", "s0", "s2", "s3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := newFrontier(2, 0)
+			for i := range tt.items {
+				item := tt.items[i]
+				fr.push(&item)
+			}
+			if _, spilled := fr.queueStats(); spilled == 0 {
+				t.Fatal("nothing spilled to disk")
+			}
+			if tt.rank != nil {
+				fr.rerank(func(pageURL string) int { return tt.rank[pageURL] })
+			}
+
+			if got := drain(fr); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+			if reason := fr.stopReason(); reason != StopExhausted {
+				t.Errorf("stop reason = %s, want %s", reason, StopExhausted)
+			}
+		})
+	}
+}
+
+func TestFrontierSpillReadError(t *testing.T) {
+	fr := newFrontier(1, 0)
+	fr.push(&workItem{URL: "a", Depth: 1})
+	fr.push(&workItem{URL: "b", Depth: 1})
+
+	// Damage the spilled entry before it is read back
+	spill := fr.spills[1]
+	spill.writer.Reset(spill.file)
+	spill.writer.WriteString("{not json\n")
+
+	for _, pageURL := range drain(fr) {
+		if pageURL == "b" {
+			t.Error("damaged entry was fetched")
+		}
+	}
+	if reason := fr.stopReason(); reason != StopSpillFailed {
+		t.Errorf("stop reason = %s, want %s", reason, StopSpillFailed)
+	}
+}
@@ -1,7 +1,13 @@
 package fetcher
 
 import (
+	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 // ConvertHTMLToMarkdown converts HTML content to clean markdown
@@ -9,63 +15,623 @@ func ConvertHTMLToMarkdown(htmlContent string) string {
 	if htmlContent == "" {
 		return ""
 	}
-	
-	// Basic HTML to markdown conversion
-	markdownContent := basicHTMLToMarkdown(htmlContent)
-	
-	return strings.TrimSpace(markdownContent)
-}
-
-// basicHTMLToMarkdown provides basic HTML to markdown conversion
-func basicHTMLToMarkdown(html string) string {
-	// Replace common HTML tags with markdown equivalents
-	replacements := map[string]string{
-		"<h1>": "# ",
-		"</h1>": "\n\n",
-		"<h2>": "## ",
-		"</h2>": "\n\n", 
-		"<h3>": "### ",
-		"</h3>": "\n\n",
-		"<h4>": "#### ",
-		"</h4>": "\n\n",
-		"<h5>": "##### ",
-		"</h5>": "\n\n",
-		"<h6>": "###### ",
-		"</h6>": "\n\n",
-		"<p>": "",
-		"</p>": "\n\n",
-		"<br>": "\n",
-		"<br/>": "\n",
-		"<strong>": "**",
-		"</strong>": "**",
-		"<b>": "**",
-		"</b>": "**",
-		"<em>": "*",
-		"</em>": "*",
-		"<i>": "*",
-		"</i>": "*",
-		"<code>": "`",
-		"</code>": "`",
-		"<pre>": "```",
-		"</pre>": "```",
-		"<ul>": "",
-		"</ul>": "\n",
-		"<ol>": "",
-		"</ol>": "\n",
-		"<li>": "- ",
-		"</li>": "\n",
-		"<blockquote>": "> ",
-		"</blockquote>": "\n\n",
-	}
-	
-	result := html
-	for htmlTag, mdReplacement := range replacements {
-		result = strings.ReplaceAll(result, htmlTag, mdReplacement)
-	}
-	
-	// Clean up extra whitespace
-	result = strings.ReplaceAll(result, "\n\n\n", "\n\n")
-	result = strings.TrimSpace(result)
-	
-	return result
-}
\ No newline at end of file
+
+	nodes, err := html.ParseFragment(strings.NewReader(htmlContent), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return ""
+	}
+
+	converter := &markdownConverter{}
+	return converter.convertNodes(nodes)
+}
+
+// markdownConverter walks an HTML DOM and renders CommonMark with GFM tables
+type markdownConverter struct {
+	baseURL     *url.URL // Resolves relative links and images; nil keeps them as written
+	tablePolicy string   // How complex tables are rendered (TablePolicyHTML or TablePolicyList)
+}
+
+// convert renders a node and its descendants as markdown
+func (mc *markdownConverter) convert(n *html.Node) string {
+	return mc.convertNodes([]*html.Node{n})
+}
+
+// convertNodes renders a sequence of sibling nodes as markdown
+func (mc *markdownConverter) convertNodes(nodes []*html.Node) string {
+	var blocks []string
+	var inline inlineWriter
+	for _, n := range nodes {
+		blocks = mc.appendNode(blocks, &inline, n)
+	}
+	blocks = mc.flushParagraph(blocks, &inline)
+
+	return strings.TrimSpace(strings.Join(blocks, "\n\n"))
+}
+
+// skippedElements never contribute content
+var skippedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Button:   true,
+	atom.Iframe:   true,
+	atom.Form:     true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Head:     true,
+	atom.Title:    true,
+	atom.Meta:     true,
+	atom.Link:     true,
+}
+
+// blockElements start a new markdown block
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Body: true, atom.Details: true, atom.Dd: true, atom.Div: true, atom.Dl: true,
+	atom.Dt: true, atom.Fieldset: true, atom.Figcaption: true, atom.Figure: true,
+	atom.Footer: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Header: true, atom.Hr: true, atom.Html: true,
+	atom.Li: true, atom.Main: true, atom.Nav: true, atom.Ol: true, atom.P: true,
+	atom.Pre: true, atom.Section: true, atom.Summary: true, atom.Table: true,
+	atom.Ul: true,
+}
+
+// isBlock reports whether a node renders as its own markdown block
+func isBlock(n *html.Node) bool {
+	return n.Type == html.ElementNode && blockElements[n.DataAtom]
+}
+
+// hasBlockDescendant reports whether an inline element wraps block content, e.g. <a><div>..</div></a>
+func hasBlockDescendant(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if isBlock(c) || (c.Type == html.ElementNode && hasBlockDescendant(c)) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendNode adds a node's output to the current blocks, buffering inline content into a paragraph
+func (mc *markdownConverter) appendNode(blocks []string, inline *inlineWriter, n *html.Node) []string {
+	switch n.Type {
+	case html.TextNode:
+		inline.text(n.Data)
+		return blocks
+	case html.DocumentNode:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			blocks = mc.appendNode(blocks, inline, c)
+		}
+		return blocks
+	case html.ElementNode:
+	default:
+		return blocks
+	}
+
+	if skippedElements[n.DataAtom] || isHidden(n) || isCodeWrapperChrome(n) {
+		return blocks
+	}
+
+	if !isBlock(n) && !hasBlockDescendant(n) {
+		mc.writeInline(inline, n)
+		return blocks
+	}
+
+	// A link wrapping blocks, such as a card or tile, becomes a paragraph of
+	// its own linking the blocks' text
+	if n.DataAtom == atom.A && linksAway(getAttr(n, "href")) {
+		blocks = mc.flushParagraph(blocks, inline)
+		mc.link(inline, n)
+		return mc.flushParagraph(blocks, inline)
+	}
+
+	blocks = mc.flushParagraph(blocks, inline)
+
+	var block string
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		block = mc.heading(n)
+	case atom.P:
+		block = mc.inlineBlock(n)
+	case atom.Pre:
+		block = mc.codeBlock(n)
+	case atom.Ul, atom.Ol:
+		block = mc.list(n)
+	case atom.Blockquote:
+		block = prefixLines(mc.convertChildren(n), "> ", ">")
+	case atom.Table:
+		if pre := codeTablePre(n); pre != nil {
+			block = mc.codeBlock(pre)
+		} else {
+			block = mc.table(n)
+		}
+	case atom.Hr:
+		block = "---"
+	case atom.Dt:
+		if text := mc.inlineBlock(n); text != "" {
+			block = "**" + text + "**"
+		}
+	case atom.Dd:
+		block = prefixLines(mc.convertChildren(n), ": ", "")
+	default:
+		// Containers (div, section, li outside a list, a wrapping blocks...) pass their blocks through
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			blocks = mc.appendNode(blocks, inline, c)
+		}
+		return mc.flushParagraph(blocks, inline)
+	}
+
+	if strings.TrimSpace(block) != "" {
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// flushParagraph turns buffered inline content into a paragraph block
+func (mc *markdownConverter) flushParagraph(blocks []string, inline *inlineWriter) []string {
+	text := inline.String()
+	inline.reset()
+
+	if text == "" {
+		return blocks
+	}
+	return append(blocks, escapeLineStarts(text))
+}
+
+// convertChildren renders the children of an element as blocks
+func (mc *markdownConverter) convertChildren(n *html.Node) string {
+	var children []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		children = append(children, c)
+	}
+	return mc.convertNodes(children)
+}
+
+// inlineBlock renders an element's content as a single run of inline markdown
+func (mc *markdownConverter) inlineBlock(n *html.Node) string {
+	var w inlineWriter
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		mc.writeInline(&w, c)
+	}
+	return escapeLineStarts(w.String())
+}
+
+// heading renders h1-h6 as an ATX heading on one line
+func (mc *markdownConverter) heading(n *html.Node) string {
+	text := strings.Join(strings.Fields(mc.inlineBlock(n)), " ")
+	text = strings.ReplaceAll(text, "\\ ", " ")
+	if text == "" {
+		return ""
+	}
+
+	level := int(n.Data[1] - '0')
+	return strings.Repeat("#", level) + " " + text
+}
+
+// list renders <ul> and <ol>, indenting each item's continuation lines under its marker
+func (mc *markdownConverter) list(n *html.Node) string {
+	ordered := n.DataAtom == atom.Ol
+	number := 1
+	if start, err := strconv.Atoi(getAttr(n, "start")); err == nil && ordered {
+		number = start
+	}
+
+	var items []string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+
+		content := mc.listItem(c)
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+prefixLines(content, indent, "")[len(indent):])
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// listItem renders an <li>, keeping its leading text and a nested list right after it on adjacent lines
+func (mc *markdownConverter) listItem(li *html.Node) string {
+	var blocks []string
+	var inline inlineWriter
+	leadsWithText := false // The item's first block is its text, not code, a table or a list
+	tight := false
+	for c := li.FirstChild; c != nil; c = c.NextSibling {
+		before := len(blocks)
+		blocks = mc.appendNode(blocks, &inline, c)
+		if before == 0 && len(blocks) > 0 {
+			// Buffered text is flushed as the first block when the next block starts
+			leadsWithText = len(blocks) == 2 || c.DataAtom == atom.P
+		}
+		if (c.DataAtom == atom.Ul || c.DataAtom == atom.Ol) && before < 2 && len(blocks) == 2 && leadsWithText {
+			tight = true
+		}
+	}
+	blocks = mc.flushParagraph(blocks, &inline)
+
+	// A nested list directly after the item text keeps the list tight
+	if tight {
+		blocks = append([]string{blocks[0] + "\n" + blocks[1]}, blocks[2:]...)
+	}
+	content := strings.TrimSpace(strings.Join(blocks, "\n\n"))
+
+	// GFM task list items
+	if checkbox := findChild(li, atom.Input); checkbox != nil && getAttr(checkbox, "type") == "checkbox" {
+		if hasAttr(checkbox, "checked") {
+			content = "[x] " + content
+		} else {
+			content = "[ ] " + content
+		}
+	}
+	return content
+}
+
+// writeInline renders an inline node into w
+func (mc *markdownConverter) writeInline(w *inlineWriter, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if skippedElements[n.DataAtom] || isHidden(n) || isCodeWrapperChrome(n) {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		w.lineBreak()
+	case atom.Strong, atom.B:
+		mc.wrapInline(w, n, "**")
+	case atom.Em, atom.I, atom.Cite:
+		mc.wrapInline(w, n, "*")
+	case atom.Del, atom.S, atom.Strike:
+		mc.wrapInline(w, n, "~~")
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		w.raw(inlineCode(textContent(n)))
+	case atom.A:
+		mc.link(w, n)
+	case atom.Img:
+		mc.image(w, n)
+	case atom.Input:
+		// Task list checkboxes are rendered by listItem
+	default:
+		// Blocks flattened into a line, e.g. inside a link, stay separate words
+		if isBlock(n) {
+			w.space()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			mc.writeInline(w, c)
+		}
+		if isBlock(n) {
+			w.space()
+		}
+	}
+}
+
+// wrapInline surrounds an element's inline content with a delimiter such as ** or *,
+// keeping surrounding whitespace outside the delimiters so the emphasis still parses
+func (mc *markdownConverter) wrapInline(w *inlineWriter, n *html.Node, delim string) {
+	var inner inlineWriter
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		mc.writeInline(&inner, c)
+	}
+
+	text := inner.String()
+	if text == "" {
+		if inner.pendingSpace {
+			w.space()
+		}
+		return
+	}
+
+	if inner.leadingSpace {
+		w.space()
+	}
+	w.raw(delim + text + delim)
+	if inner.pendingSpace {
+		w.space()
+	}
+}
+
+// link renders <a href> as [text](url); heading permalinks and script links are dropped
+func (mc *markdownConverter) link(w *inlineWriter, n *html.Node) {
+	var inner inlineWriter
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		mc.writeInline(&inner, c)
+	}
+	text := inner.String()
+
+	href := strings.TrimSpace(getAttr(n, "href"))
+	switch {
+	case !linksAway(href) && !strings.HasPrefix(href, "#"):
+		if text != "" {
+			w.raw(text)
+		}
+		return
+	case strings.HasPrefix(href, "#"):
+		// Permalink anchors such as "¶" or "#" next to headings carry no content
+		if !containsWordCharacter(text) {
+			return
+		}
+		w.raw(text)
+		return
+	}
+
+	if text == "" {
+		return
+	}
+
+	if inner.leadingSpace {
+		w.space()
+	}
+	w.raw("[" + text + "](" + mc.resolve(href) + ")")
+	if inner.pendingSpace {
+		w.space()
+	}
+}
+
+// linksAway reports whether an href leads to another page or resource,
+// rather than nowhere, a script or an anchor on the same page
+func linksAway(href string) bool {
+	href = strings.TrimSpace(href)
+	return href != "" && !strings.HasPrefix(href, "#") && !strings.HasPrefix(strings.ToLower(href), "javascript:")
+}
+
+// image renders <img> as ![alt](src); inline data URIs keep only their alt text
+func (mc *markdownConverter) image(w *inlineWriter, n *html.Node) {
+	src := strings.TrimSpace(getAttr(n, "src"))
+	alt := strings.Join(strings.Fields(getAttr(n, "alt")), " ")
+
+	if src == "" || strings.HasPrefix(src, "data:") {
+		if alt != "" {
+			w.text(alt)
+		}
+		return
+	}
+	w.raw("![" + escapeInline(alt) + "](" + mc.resolve(src) + ")")
+}
+
+// resolve makes a link absolute against the converter's base URL
+func (mc *markdownConverter) resolve(href string) string {
+	if mc.baseURL != nil {
+		if resolved, err := mc.baseURL.Parse(href); err == nil {
+			href = resolved.String()
+		}
+	}
+
+	// Spaces and parentheses would end the link destination early
+	if strings.ContainsAny(href, " ()") {
+		return "<" + href + ">"
+	}
+	return href
+}
+
+// inlineCode wraps text in enough backticks that any backticks inside survive
+func inlineCode(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return ""
+	}
+
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		text = " " + text + " "
+	}
+	return fence + text + fence
+}
+
+// inlineWriter accumulates inline markdown, collapsing HTML whitespace the way a browser would
+type inlineWriter struct {
+	sb           strings.Builder
+	pendingSpace bool // Whitespace seen that has not been written yet
+	leadingSpace bool // Content began with whitespace
+}
+
+// text writes escaped text, collapsing whitespace runs to single spaces
+func (w *inlineWriter) text(s string) {
+	for i, word := range strings.Fields(s) {
+		if i == 0 && startsWithSpace(s) {
+			w.space()
+		} else if i > 0 {
+			w.space()
+		}
+		w.raw(escapeInline(word))
+	}
+	if strings.TrimSpace(s) == "" && s != "" {
+		w.space()
+	} else if endsWithSpace(s) {
+		w.space()
+	}
+}
+
+// raw writes markdown as-is after any pending space
+func (w *inlineWriter) raw(s string) {
+	if s == "" {
+		return
+	}
+	if w.pendingSpace && w.sb.Len() > 0 && !strings.HasSuffix(w.sb.String(), "\n") {
+		w.sb.WriteByte(' ')
+	}
+	w.pendingSpace = false
+	w.sb.WriteString(s)
+}
+
+// space records whitespace to be written before the next content
+func (w *inlineWriter) space() {
+	if w.sb.Len() == 0 {
+		w.leadingSpace = true
+	}
+	w.pendingSpace = true
+}
+
+// lineBreak writes a hard line break
+func (w *inlineWriter) lineBreak() {
+	if w.sb.Len() > 0 {
+		w.sb.WriteString("\\\n")
+	}
+	w.pendingSpace = false
+}
+
+// String returns the trimmed inline markdown
+func (w *inlineWriter) String() string {
+	return strings.TrimSuffix(strings.TrimSpace(w.sb.String()), "\\")
+}
+
+// reset clears the writer for the next paragraph
+func (w *inlineWriter) reset() {
+	w.sb.Reset()
+	w.pendingSpace = false
+	w.leadingSpace = false
+}
+
+// inlineEscaper escapes characters that would otherwise start markdown formatting
+var inlineEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"`", "\\`",
+	"<", `\<`,
+	"[", `\[`,
+	"]", `\]`,
+)
+
+// escapeInline escapes markdown syntax in plain text. Underscores are only
+// escaped at word edges, since snake_case identifiers are common in docs.
+func escapeInline(text string) string {
+	text = inlineEscaper.Replace(text)
+	if strings.HasPrefix(text, "_") {
+		text = `\` + text
+	}
+	if strings.HasSuffix(text, "_") && len(text) > 1 {
+		text = text[:len(text)-1] + `\_`
+	}
+	return text
+}
+
+// lineStartMarker matches text at the start of a line that markdown would read as a heading, list or quote
+var lineStartMarker = regexp.MustCompile(`(?m)^(#{1,6}(?:\s|$)|[-+](?:\s|$)|>|\d+[.)](?:\s|$)|={3,}|-{3,})`)
+
+// escapeLineStarts escapes block markers at the start of paragraph lines
+func escapeLineStarts(text string) string {
+	return lineStartMarker.ReplaceAllStringFunc(text, func(marker string) string {
+		// Backslashes only escape punctuation, so a number keeps its digits and escapes the delimiter
+		if marker[0] >= '0' && marker[0] <= '9' {
+			i := strings.IndexAny(marker, ".)")
+			return marker[:i] + `\` + marker[i:]
+		}
+		return `\` + marker
+	})
+}
+
+// prefixLines prefixes each line of text, using blankPrefix for empty lines
+func prefixLines(text, prefix, blankPrefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = blankPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// textContent returns the raw text of a node and its descendants, whitespace untouched
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			sb.WriteString(n.Data)
+		case n.Type == html.ElementNode && n.DataAtom == atom.Br:
+			sb.WriteString("\n")
+		case n.Type == html.ElementNode && (skippedElements[n.DataAtom] || isHidden(n)):
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+// isHidden reports whether an element is explicitly hidden from readers
+func isHidden(n *html.Node) bool {
+	if hasAttr(n, "hidden") || getAttr(n, "aria-hidden") == "true" {
+		return true
+	}
+	style := strings.ReplaceAll(strings.ToLower(getAttr(n, "style")), " ", "")
+	return strings.Contains(style, "display:none")
+}
+
+// getAttr returns an attribute value, or "" if it is missing
+func getAttr(n *html.Node, name string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// hasAttr reports whether an element has an attribute, even an empty one
+func hasAttr(n *html.Node, name string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == name {
+			return true
+		}
+	}
+	return false
+}
+
+// findChild returns the first descendant element with the given tag
+func findChild(n *html.Node, tag atom.Atom) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == tag {
+			return c
+		}
+		if found := findChild(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// startsWithSpace reports whether s begins with HTML whitespace
+func startsWithSpace(s string) bool {
+	return s != "" && strings.ContainsRune(" \t\n\r\f", rune(s[0]))
+}
+
+// endsWithSpace reports whether s ends with HTML whitespace
+func endsWithSpace(s string) bool {
+	return s != "" && strings.ContainsRune(" \t\n\r\f", rune(s[len(s)-1]))
+}
+
+// containsWordCharacter reports whether text has any letter or digit
+func containsWordCharacter(text string) bool {
+	for _, r := range text {
+		if r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 127 && r != '¶' {
+			return true
+		}
+	}
+	return false
+}
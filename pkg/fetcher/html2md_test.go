@@ -0,0 +1,119 @@
+package fetcher
+
+import "testing"
+
+func TestConvertHTMLToMarkdownLists(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "code block in an item keeps its blank line",
+			html: `<ul><li>Install:<pre><code>npm i doc-fetch</code></pre></li><li>Run it</li></ul>`,
+			want: "- Install:\n\n  ```\n  npm i doc-fetch\n  ```\n- Run it",
+		},
+		{
+			name: "code that looks like a list is left alone",
+			html: "<ul><li>Config:<pre><code>a: 1\n\n- b</code></pre></li></ul>",
+			want: "- Config:\n\n  ```\n  a: 1\n\n  - b\n  ```",
+		},
+		{
+			name: "nested lists follow their item text directly",
+			html: `<ol><li>First<ul><li>nested a</li><li>nested b</li></ul></li><li>Second<ol><li>inner</li></ol></li></ol>`,
+			want: "1. First\n   - nested a\n   - nested b\n2. Second\n   1. inner",
+		},
+		{
+			name: "paragraphs in an item stay apart",
+			html: `<ul><li><p>Para one</p><p>Para two</p></li></ul>`,
+			want: "- Para one\n\n  Para two",
+		},
+		{
+			name: "task items",
+			html: `<ul><li><input type="checkbox" checked> done</li><li><input type="checkbox"> todo</li></ul>`,
+			want: "- [x] done\n- [ ] todo",
+		},
+		{
+			name: "task item with a nested list",
+			html: `<ul><li><input type="checkbox" checked> done<ul><li>detail</li></ul></li></ul>`,
+			want: "- [x] done\n  - detail",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ConvertHTMLToMarkdown(tt.html); got != tt.want {
+				t.Errorf("ConvertHTMLToMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertHTMLToMarkdownEscapesLineStarts(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{name: "ordered marker with a dot", html: `<p>1. not a list</p>`, want: `1\. not a list`},
+		{name: "ordered marker with a parenthesis", html: `<p>2) not a list</p>`, want: `2\) not a list`},
+		{name: "heading", html: `<p># not a heading</p>`, want: `\# not a heading`},
+		{name: "dash bullet", html: `<p>- not an item</p>`, want: `\- not an item`},
+		{name: "plus bullet", html: `<p>+ not an item</p>`, want: `\+ not an item`},
+		{name: "quote", html: `<p>&gt; not a quote</p>`, want: `\> not a quote`},
+		{name: "setext underline", html: `<p>===</p>`, want: `\===`},
+		{name: "thematic break", html: `<p>---</p>`, want: `\---`},
+		{name: "number inside a line", html: `<p>Version 1. is fine</p>`, want: `Version 1. is fine`},
+		{name: "marker after a line break", html: `<p>line<br>3. second line</p>`, want: "line\\\n3\\. second line"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ConvertHTMLToMarkdown(tt.html); got != tt.want {
+				t.Errorf("ConvertHTMLToMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertHTMLToMarkdownBlockLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "link wrapping a block",
+			html: `<a href="/x"><div>Card</div></a>`,
+			want: "[Card](/x)",
+		},
+		{
+			name: "cards become one paragraph each",
+			html: `<div><a href="/x"><div><h3>Start</h3><p>Learn the basics</p></div></a><a href="/y"><div>Other</div></a></div>`,
+			want: "[Start Learn the basics](/x)\n\n[Other](/y)",
+		},
+		{
+			name: "image card",
+			html: `<a href="/logo"><div><img src="/a.png" alt="Logo"></div></a>`,
+			want: "[![Logo](/a.png)](/logo)",
+		},
+		{
+			name: "same-page anchor keeps its blocks",
+			html: `<a href="#top"><div>Back</div></a>`,
+			want: "Back",
+		},
+		{
+			name: "anchor without href keeps its blocks",
+			html: `<a><div>No href</div></a>`,
+			want: "No href",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ConvertHTMLToMarkdown(tt.html); got != tt.want {
+				t.Errorf("ConvertHTMLToMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package fetcher
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Output formats for single-file output
+const (
+	FormatMarkdown = "markdown" // One Markdown document with a section per page
+	FormatJSONL    = "jsonl"    // One JSON record per line, one line per page
+	FormatChunks   = "chunks"   // One JSON record per line, one line per token-limited chunk
+)
+
+// outputFormats lists every format Config.Format accepts
+var outputFormats = []string{FormatMarkdown, FormatJSONL, FormatChunks}
+
+// checkOutputFormat reports an unknown format, or a single-file format combined with an output directory
+func checkOutputFormat(config *Config) error {
+	known := false
+	for _, format := range outputFormats {
+		if config.Format == format {
+			known = true
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown output format %q (use %s)", config.Format, strings.Join(outputFormats, ", "))
+	}
+	if config.Format != FormatMarkdown && config.OutputDir != "" {
+		return fmt.Errorf("%s output is a single file and cannot be combined with an output directory", config.Format)
+	}
+	if config.Grouped && (config.Format != FormatMarkdown || config.OutputDir != "") {
+		return fmt.Errorf("grouped output is a single Markdown file and cannot be combined with %s output or an output directory", config.Format)
+	}
+	if config.NavOrder && (config.Format != FormatMarkdown || config.OutputDir != "") {
+		return fmt.Errorf("sidebar ordering applies to a single Markdown file and cannot be combined with %s output or an output directory", config.Format)
+	}
+	return nil
+}
+
+// PageRecord is one page of JSONL output
+type PageRecord struct {
+	URL          string     `json:"url"`
+	CanonicalURL string     `json:"canonical_url"`
+	Title        string     `json:"title"`
+	Type         string     `json:"type"` // ClassifyPage category: API, GUIDE, REFERENCE or EXAMPLE
+	Description  string     `json:"description"`
+	Depth        int        `json:"depth"`
+	Parent       string     `json:"parent,omitempty"`
+	FetchedAt    *time.Time `json:"fetched_at,omitempty"` // Omitted in reproducible output
+	Status       int        `json:"status"`
+	ContentHash  string     `json:"content_hash"` // "sha256:" and the hex digest of Content
+	Profile      string     `json:"profile,omitempty"`
+	Strategy     string     `json:"strategy"`
+	Confidence   float64    `json:"confidence"`
+	Content      string     `json:"content"` // Markdown body
+}
+
+// newPageRecord builds the JSONL record for a page
+func newPageRecord(page *PageResult) PageRecord {
+	cleanTitle := CleanTitle(page.Title)
+	canonicalURL := page.CanonicalURL
+	if canonicalURL == "" {
+		canonicalURL = page.URL
+	}
+
+	var fetchedAt *time.Time
+	if !page.FetchedAt.IsZero() {
+		utc := page.FetchedAt.UTC()
+		fetchedAt = &utc
+	}
+
+	return PageRecord{
+		URL:          page.URL,
+		CanonicalURL: canonicalURL,
+		Title:        page.Title,
+		Type:         ClassifyPage(page.URL, cleanTitle),
+		Description:  ExtractDescription(page.Content),
+		Depth:        page.Depth,
+		Parent:       page.Parent,
+		FetchedAt:    fetchedAt,
+		Status:       page.Status,
+		ContentHash:  contentHash(page.Content),
+		Profile:      page.Profile,
+		Strategy:     page.Strategy,
+		Confidence:   page.Confidence,
+		Content:      page.Content,
+	}
+}
+
+// contentHash identifies a page body, so pipelines can skip unchanged pages
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// writeResultsJSONL writes one JSON record per page
+func writeResultsJSONL(outputPath string, resultsChan <-chan *PageResult) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriterSize(file, 32*1024)
+
+	// Markdown is full of <, > and &; keep it readable
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+
+	count := 0
+	for result := range resultsChan {
+		if strings.TrimSpace(result.Content) == "" {
+			continue
+		}
+		if err := encoder.Encode(newPageRecord(result)); err != nil {
+			return err
+		}
+
+		count++
+		if count%10 == 0 {
+			writer.Flush()
+		}
+	}
+
+	// A full disk may only show up on the last flush or on close
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,20 @@
+package fetcher
+
+import (
+	"os"
+	"testing"
+)
+
+func TestWriteResultsJSONLReportsFullDisk(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full on this system")
+	}
+
+	results := make(chan *PageResult, 1)
+	results <- &PageResult{URL: "https://docs.example.com/", Title: "Home", Content: "Welcome"}
+	close(results)
+
+	if err := writeResultsJSONL("/dev/full", results); err == nil {
+		t.Error("writeResultsJSONL() = nil, want the error of the final flush")
+	}
+}
@@ -3,8 +3,13 @@ package fetcher
 import (
 	"bufio"
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/PuerkitoBio/goquery"
 )
 
 // GenerateLLMTxt creates an llm.txt file with AI-friendly documentation index
@@ -33,4 +38,297 @@ func GenerateLLMTxt(entries []LLMTxtEntry, outputPath string) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// LLM.txt formats
+const (
+	LLMTxtSpec   = "spec"   // llms.txt and llms-full.txt as described at llmstxt.org
+	LLMTxtLegacy = "legacy" // The original "[TYPE] Title" llm.txt
+)
+
+// How llms.txt is split into sections
+const (
+	LLMTxtSectionsAuto = "auto" // The site's sidebar groups if it has them, otherwise page types
+	LLMTxtSectionsNav  = "nav"  // The site's sidebar groups; pages not in the sidebar go to "Optional"
+	LLMTxtSectionsType = "type" // ClassifyPage types: Docs, Guides, Reference, API, Examples
+)
+
+// checkLLMTxtOptions reports an unknown llms.txt format or section mode; empty values are allowed
+func checkLLMTxtOptions(config *Config) error {
+	switch config.LLMTxtFormat {
+	case "", LLMTxtSpec, LLMTxtLegacy:
+	default:
+		return fmt.Errorf("unknown llm.txt format %q (use %q or %q)", config.LLMTxtFormat, LLMTxtSpec, LLMTxtLegacy)
+	}
+	switch config.LLMTxtSections {
+	case "", LLMTxtSectionsAuto, LLMTxtSectionsNav, LLMTxtSectionsType:
+	default:
+		return fmt.Errorf("unknown llm.txt sections %q (use %q, %q or %q)", config.LLMTxtSections, LLMTxtSectionsAuto, LLMTxtSectionsNav, LLMTxtSectionsType)
+	}
+	return nil
+}
+
+// LLMsTxtPaths returns where llms.txt and llms-full.txt are written: inside
+// the output directory, or next to the output file
+func LLMsTxtPaths(config Config) (index, full string) {
+	dir := config.OutputDir
+	if dir == "" {
+		dir = filepath.Dir(config.OutputPath)
+	}
+	return filepath.Join(dir, "llms.txt"), filepath.Join(dir, "llms-full.txt")
+}
+
+// LLMTxtSite is the header of an llms.txt file
+type LLMTxtSite struct {
+	Name    string // H1: the project or site name
+	Summary string // Blockquote under the name
+}
+
+// LLMTxtSection is an H2 section of an llms.txt file
+type LLMTxtSection struct {
+	Name    string
+	Entries []LLMTxtEntry
+}
+
+// typeSections name the sections built from ClassifyPage, in the order they are written
+var typeSections = []struct{ Type, Name string }{
+	{"SECTION", "Docs"},
+	{"GUIDE", "Guides"},
+	{"REFERENCE", "Reference"},
+	{"API", "API"},
+	{"EXAMPLE", "Examples"},
+}
+
+// GroupLLMTxtByType builds sections from each entry's ClassifyPage type
+func GroupLLMTxtByType(entries []LLMTxtEntry) []LLMTxtSection {
+	var sections []LLMTxtSection
+	for _, section := range typeSections {
+		var matched []LLMTxtEntry
+		for _, entry := range entries {
+			if strings.EqualFold(entry.Type, section.Type) {
+				matched = append(matched, entry)
+			}
+		}
+		if len(matched) > 0 {
+			sections = append(sections, LLMTxtSection{Name: section.Name, Entries: matched})
+		}
+	}
+	return sections
+}
+
+// groupLLMTxtByNav builds sections from the site's sidebar groups, in sidebar
+// order. Pages the sidebar does not list go to the spec's "Optional" section,
+// which readers may skip when context is short.
+func groupLLMTxtByNav(entries []LLMTxtEntry, nav []navSection) []LLMTxtSection {
+	index := sectionIndex(nav)
+	position := make(map[string]int)
+	for _, section := range nav {
+		for i, key := range section.URLs {
+			position[key] = i
+		}
+	}
+
+	grouped := make([][]LLMTxtEntry, len(nav))
+	var other []LLMTxtEntry
+	for _, entry := range entries {
+		if i, ok := index[canonicalKeyString(entry.URL)]; ok {
+			grouped[i] = append(grouped[i], entry)
+		} else {
+			other = append(other, entry)
+		}
+	}
+
+	var sections []LLMTxtSection
+	for i, section := range nav {
+		if len(grouped[i]) == 0 {
+			continue
+		}
+		sort.SliceStable(grouped[i], func(a, b int) bool {
+			return position[canonicalKeyString(grouped[i][a].URL)] < position[canonicalKeyString(grouped[i][b].URL)]
+		})
+		name := section.Title
+		if name == "" {
+			name = "Docs"
+		}
+		sections = append(sections, LLMTxtSection{Name: name, Entries: grouped[i]})
+	}
+	if len(other) > 0 {
+		sections = append(sections, LLMTxtSection{Name: "Optional", Entries: other})
+	}
+	return sections
+}
+
+// GenerateLLMsTxt writes llms.txt: the site name as H1, its summary as a
+// blockquote and an H2 per section listing "- [title](url): description".
+// If fullPath is set it also writes llms-full.txt, which has the same
+// outline with every page's content under its title.
+func GenerateLLMsTxt(site LLMTxtSite, sections []LLMTxtSection, outputPath, fullPath string) error {
+	var sb strings.Builder
+	writeLLMsHeader(&sb, site)
+	for _, section := range sections {
+		fmt.Fprintf(&sb, "## %s\n\n", section.Name)
+		for _, entry := range section.Entries {
+			fmt.Fprintf(&sb, "- [%s](%s)", escapeInline(oneLine(entry.Title)), entry.URL)
+			if description := oneLine(entry.Description); description != "" {
+				fmt.Fprintf(&sb, ": %s", description)
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+	if err := os.WriteFile(outputPath, []byte(strings.TrimRight(sb.String(), "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write llms.txt: %w", err)
+	}
+
+	if fullPath == "" {
+		return nil
+	}
+
+	sb.Reset()
+	writeLLMsHeader(&sb, site)
+	for _, section := range sections {
+		fmt.Fprintf(&sb, "## %s\n\n", section.Name)
+		for _, entry := range section.Entries {
+			fmt.Fprintf(&sb, "### %s\n\n", oneLine(entry.Title))
+			fmt.Fprintf(&sb, "Source: %s\n\n", entry.URL)
+			if content := strings.TrimSpace(entry.Content); content != "" {
+				// Page headings go below the page title so the outline stays intact
+				sb.WriteString(shiftHeadings(content, 3))
+				sb.WriteString("\n\n")
+			}
+		}
+	}
+	if err := os.WriteFile(fullPath, []byte(strings.TrimRight(sb.String(), "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write llms-full.txt: %w", err)
+	}
+	return nil
+}
+
+// writeLLMsHeader writes the H1 name and blockquote summary
+func writeLLMsHeader(sb *strings.Builder, site LLMTxtSite) {
+	fmt.Fprintf(sb, "# %s\n\n", oneLine(site.Name))
+	if summary := oneLine(site.Summary); summary != "" {
+		fmt.Fprintf(sb, "> %s\n\n", summary)
+	}
+}
+
+// oneLine collapses text onto a single line
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// shiftHeadings demotes every ATX heading outside code blocks by levels, down to at most H6
+func shiftHeadings(markdown string, levels int) string {
+	lines := strings.Split(markdown, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if fence != "" {
+			if closesCodeFence(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if marker := codeFenceMarker(trimmed); marker != "" {
+			fence = marker
+			continue
+		}
+
+		if level := headingLevel(line); level > 0 {
+			lines[i] = strings.Repeat("#", min(level+levels, 6)) + line[level:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// llmTxtSite is the site name and summary a page suggests: og:site_name or
+// the page title for the name, its description for the summary
+func llmTxtSite(siteName, title, description string) LLMTxtSite {
+	if siteName == "" {
+		siteName = CleanTitle(title)
+	}
+	return LLMTxtSite{Name: siteName, Summary: description}
+}
+
+// llmTxtDescription is a page's meta description, or the start of its content
+func llmTxtDescription(doc *goquery.Document, content string) string {
+	for _, selector := range []string{"meta[name='description']", "meta[property='og:description']"} {
+		if description := oneLine(doc.Find(selector).AttrOr("content", "")); description != "" {
+			return description
+		}
+	}
+	return ExtractDescription(content)
+}
+
+// recordLLMTxtSite keeps the site header from the earliest page of the crawl
+func (f *OptimizedFetcher) recordLLMTxtSite(site LLMTxtSite, item *workItem) {
+	f.llmMutex.Lock()
+	defer f.llmMutex.Unlock()
+	if f.llmSite == nil || item.Order < f.llmSiteOrder {
+		f.llmSite, f.llmSiteOrder = &site, item.Order
+	}
+}
+
+// writeLLMsTxt writes llms.txt and llms-full.txt for the pages fetched
+func (f *OptimizedFetcher) writeLLMsTxt(indexPath, fullPath string) error {
+	f.llmMutex.Lock()
+	defer f.llmMutex.Unlock()
+
+	entries := f.llmEntriesInOrder()
+
+	site := LLMTxtSite{Name: f.baseURL.Host}
+	if f.llmSite != nil && f.llmSite.Name != "" {
+		site = *f.llmSite
+	}
+
+	// "Install | MyLib" is just "Install" under the MyLib heading
+	for i := range entries {
+		entries[i].Title = trimSiteName(entries[i].Title, site.Name)
+	}
+
+	var sections []LLMTxtSection
+	nav := f.navTree().sections()
+	switch {
+	case f.config.LLMTxtSections == LLMTxtSectionsType:
+		sections = GroupLLMTxtByType(entries)
+	case nav != nil:
+		sections = groupLLMTxtByNav(entries, nav)
+	case f.config.LLMTxtSections == LLMTxtSectionsNav:
+		// No sidebar found: everything is optional rather than guessed at
+		log.Printf("⚠️  Warning: No documentation sidebar found; llms.txt lists every page under Optional")
+		sections = groupLLMTxtByNav(entries, nil)
+	default:
+		sections = GroupLLMTxtByType(entries)
+	}
+
+	return GenerateLLMsTxt(site, sections, indexPath, fullPath)
+}
+
+// llmEntriesInOrder copies the llms.txt entries in page order, the
+// reproducible one when that was asked for
+func (f *OptimizedFetcher) llmEntriesInOrder() []LLMTxtEntry {
+	entries := append([]LLMTxtEntry(nil), f.llmEntries...)
+	if f.config.Reproducible {
+		for i := range entries {
+			entries[i].Order = f.stableOrderOf(entries[i].URL, int64(len(entries)))
+			entries[i].Content = normalizeWhitespace(entries[i].Content)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Order != entries[j].Order {
+			return entries[i].Order < entries[j].Order
+		}
+		return entries[i].URL < entries[j].URL
+	})
+	return entries
+}
+
+// trimSiteName removes a " | Site" or " - Site" suffix from a page title
+func trimSiteName(title, site string) string {
+	for _, sep := range []string{" | ", " - ", " – ", " — ", " · "} {
+		if trimmed := strings.TrimSuffix(title, sep+site); trimmed != title && strings.TrimSpace(trimmed) != "" {
+			return strings.TrimSpace(trimmed)
+		}
+	}
+	return title
+}
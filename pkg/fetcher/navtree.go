@@ -0,0 +1,464 @@
+package fetcher
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// NavNode is an entry of a documentation sidebar: a page, a category or a
+// caption, with the entries nested under it in sidebar order
+type NavNode struct {
+	Title    string
+	URL      string // Absolute page URL; "" for captions and categories without a page
+	Children []*NavNode
+	caption  bool // A heading such as Sphinx's p.caption rather than a list item
+}
+
+// NavTree is a documentation sidebar in the author's reading order
+type NavTree struct {
+	Roots     []*NavNode
+	positions map[string]navPosition // Canonical key of every page in the sidebar
+}
+
+// navPosition is where a page sits in the sidebar
+type navPosition struct {
+	Rank  int // 1-based position reading the sidebar top to bottom
+	Depth int // Nesting below the top level
+}
+
+// navSection is a top-level group of the documentation sidebar, such as a
+// Docusaurus category or a Sphinx caption, with its pages in sidebar order
+type navSection struct {
+	Title string
+	URLs  []string // Canonical keys
+}
+
+// genericNavSelectors find a documentation sidebar on pages without a profile
+var genericNavSelectors = []string{"aside nav", "nav[aria-label*='docs' i]", "nav[aria-label*='sidebar' i]", ".sidebar nav", "nav.sidebar"}
+
+// ExtractNavTree reads a page's documentation sidebar, or returns nil if it has none
+func ExtractNavTree(doc *goquery.Document) *NavTree {
+	return navTreeFromPage(doc, detectProfile(doc))
+}
+
+// navTreeFromPage reads the sidebar of a page into a tree. Captions
+// (headings, Sphinx's p.caption) become top-level nodes holding the lists
+// that follow them; list items nest as they do in the page.
+func navTreeFromPage(doc *goquery.Document, profile *siteProfile) *NavTree {
+	root := navRoot(doc, profile)
+	if root == nil {
+		return nil
+	}
+
+	base := doc.Url
+	tree := &NavTree{}
+	var caption *NavNode // Caption that lists go under
+
+	var list func(*html.Node) []*NavNode
+	list = func(n *html.Node) []*NavNode {
+		var nodes []*NavNode
+		for li := n.FirstChild; li != nil; li = li.NextSibling {
+			if li.Type != html.ElementNode || li.DataAtom != atom.Li {
+				continue
+			}
+			node := &NavNode{Title: navItemLabel(li), URL: navItemURL(base, li)}
+			for _, nested := range nestedLists(li) {
+				node.Children = append(node.Children, list(nested)...)
+			}
+			if node.URL != "" || len(node.Children) > 0 {
+				nodes = append(nodes, node)
+			}
+		}
+		return nodes
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || isHidden(c) {
+				continue
+			}
+
+			switch {
+			case isNavCaption(c):
+				if title := innerText(c); title != "" {
+					caption = &NavNode{Title: title, caption: true}
+					tree.Roots = append(tree.Roots, caption)
+				}
+			case c.DataAtom == atom.Ul || c.DataAtom == atom.Ol:
+				if caption != nil {
+					caption.Children = append(caption.Children, list(c)...)
+				} else {
+					tree.Roots = append(tree.Roots, list(c)...)
+				}
+			default:
+				walk(c)
+			}
+		}
+	}
+
+	// Profile selectors often match the top-level list itself
+	if root.DataAtom == atom.Ul || root.DataAtom == atom.Ol {
+		tree.Roots = list(root)
+	} else {
+		walk(root)
+	}
+
+	tree.index()
+	if len(tree.positions) == 0 {
+		return nil
+	}
+	return tree
+}
+
+// index numbers the pages top to bottom; a page listed twice keeps its first place
+func (t *NavTree) index() {
+	t.positions = make(map[string]navPosition)
+	var visit func(nodes []*NavNode, depth int)
+	visit = func(nodes []*NavNode, depth int) {
+		for _, node := range nodes {
+			if node.URL != "" {
+				key := canonicalKeyString(node.URL)
+				if _, ok := t.positions[key]; !ok {
+					t.positions[key] = navPosition{Rank: len(t.positions) + 1, Depth: depth}
+				}
+			}
+			visit(node.Children, depth+1)
+		}
+	}
+	visit(t.Roots, 0)
+}
+
+// Position returns where a page sits in the sidebar: its 1-based rank top
+// to bottom and its nesting depth, or ok false if the sidebar does not list it
+func (t *NavTree) Position(pageURL string) (rank, depth int, ok bool) {
+	if t == nil {
+		return 0, 0, false
+	}
+	position, ok := t.positions[canonicalKeyString(pageURL)]
+	return position.Rank, position.Depth, ok
+}
+
+// Pages counts the distinct pages in the sidebar
+func (t *NavTree) Pages() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.positions)
+}
+
+// String renders the tree as a nested Markdown list
+func (t *NavTree) String() string {
+	var sb strings.Builder
+	var write func(nodes []*NavNode, depth int)
+	write = func(nodes []*NavNode, depth int) {
+		for _, node := range nodes {
+			indent := strings.Repeat("  ", depth)
+			if node.URL != "" {
+				fmt.Fprintf(&sb, "%s- [%s](%s)\n", indent, escapeInline(node.Title), node.URL)
+			} else {
+				fmt.Fprintf(&sb, "%s- %s\n", indent, escapeInline(node.Title))
+			}
+			write(node.Children, depth+1)
+		}
+	}
+	if t != nil {
+		write(t.Roots, 0)
+	}
+	return sb.String()
+}
+
+// sections splits the sidebar into its top-level groups: captions, and in a
+// sidebar without captions every top-level entry with children. Other
+// top-level pages join the caption before them, or an untitled section.
+// A sidebar without any grouping says nothing about sections, so it has none.
+func (t *NavTree) sections() []navSection {
+	if t == nil {
+		return nil
+	}
+
+	var sections []navSection
+	current := -1 // Section that loose pages go to
+	captioned := false
+	for _, node := range t.Roots {
+		keys := nodeKeys(node)
+		switch {
+		case node.caption:
+			sections = append(sections, navSection{Title: node.Title, URLs: keys})
+			current = len(sections) - 1
+			captioned = true
+		case len(node.Children) > 0 && !captioned:
+			sections = append(sections, navSection{Title: node.Title, URLs: keys})
+		default:
+			if current < 0 {
+				sections = append(sections, navSection{})
+				current = len(sections) - 1
+			}
+			sections[current].URLs = append(sections[current].URLs, keys...)
+		}
+	}
+
+	var result []navSection
+	for _, section := range sections {
+		if len(section.URLs) > 0 {
+			result = append(result, section)
+		}
+	}
+	if len(result) < 2 {
+		return nil
+	}
+	return result
+}
+
+// nodeKeys lists the canonical keys of a node's page and every page below it
+func nodeKeys(node *NavNode) []string {
+	var keys []string
+	if node.URL != "" {
+		keys = append(keys, canonicalKeyString(node.URL))
+	}
+	for _, child := range node.Children {
+		keys = append(keys, nodeKeys(child)...)
+	}
+	return keys
+}
+
+// navRoot finds the element holding the documentation sidebar
+func navRoot(doc *goquery.Document, profile *siteProfile) *html.Node {
+	var selectors []string
+	if profile != nil {
+		selectors = append(selectors, profile.Nav...)
+	}
+	selectors = append(selectors, genericNavSelectors...)
+
+	for _, selector := range selectors {
+		if el := doc.Find(selector).First(); el.Length() > 0 && el.Find("a[href]").Length() > 0 {
+			return el.Get(0)
+		}
+	}
+	return nil
+}
+
+// isNavCaption reports whether an element titles the links that follow it
+func isNavCaption(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		return true
+	}
+	return hasClass(n, "caption") && findChild(n, atom.A) == nil
+}
+
+// nestedLists finds the outermost lists inside a list item; MkDocs wraps them in a nav
+func nestedLists(li *html.Node) []*html.Node {
+	var lists []*html.Node
+	var visit func(*html.Node)
+	visit = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if c.DataAtom == atom.Ul || c.DataAtom == atom.Ol {
+				lists = append(lists, c)
+				continue
+			}
+			visit(c)
+		}
+	}
+	visit(li)
+	return lists
+}
+
+// navItemLabel is the text of a list item without its sub-list
+func navItemLabel(li *html.Node) string {
+	var parts []string
+	for c := li.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (c.DataAtom == atom.Ul || c.DataAtom == atom.Ol) {
+			continue
+		}
+		if c.Type == html.ElementNode && (findChild(c, atom.Ul) != nil || findChild(c, atom.Ol) != nil) {
+			continue
+		}
+		if text := innerText(c); text != "" {
+			parts = append(parts, text)
+		} else if c.Type == html.TextNode {
+			if text := strings.TrimSpace(c.Data); text != "" {
+				parts = append(parts, text)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// navItemURL is the page a list item links to outside its sub-lists, or ""
+func navItemURL(base *url.URL, li *html.Node) string {
+	var found string
+	var visit func(*html.Node) bool
+	visit = func(n *html.Node) bool {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || c.DataAtom == atom.Ul || c.DataAtom == atom.Ol {
+				continue
+			}
+			if c.DataAtom == atom.A {
+				if pageURL := navLinkURL(base, getAttr(c, "href")); pageURL != "" {
+					found = pageURL
+					return true
+				}
+			}
+			if visit(c) {
+				return true
+			}
+		}
+		return false
+	}
+	visit(li)
+	return found
+}
+
+// navLinkURL resolves a sidebar link to an absolute page URL, or "" for links that are not pages
+func navLinkURL(base *url.URL, href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return ""
+	}
+
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// sectionIndex maps each page in the nav to its section
+func sectionIndex(sections []navSection) map[string]int {
+	index := make(map[string]int)
+	for i, section := range sections {
+		for _, key := range section.URLs {
+			if _, ok := index[key]; !ok {
+				index[key] = i
+			}
+		}
+	}
+	return index
+}
+
+// recordNav keeps the sidebar of the earliest page in the crawl that has one,
+// normally the start page
+func (f *OptimizedFetcher) recordNav(doc *goquery.Document, item *workItem, profile *siteProfile) {
+	f.navMutex.Lock()
+	found := f.nav != nil && f.navOrder <= item.Order
+	f.navMutex.Unlock()
+	if found {
+		return
+	}
+
+	if tree := navTreeFromPage(doc, profile); tree != nil {
+		f.useNav(tree, item)
+	}
+}
+
+// useNav keeps a page's sidebar unless an earlier page's is already known,
+// and re-ranks the queue by it; URLs queued before it was found, like
+// sitemap seeds, would otherwise wait behind every sidebar page
+func (f *OptimizedFetcher) useNav(tree *NavTree, item *workItem) {
+	f.navMutex.Lock()
+	defer f.navMutex.Unlock()
+	if f.nav == nil || item.Order < f.navOrder {
+		if f.nav == nil {
+			log.Printf("🧭 Sidebar found on %s: %d pages", item.URL, tree.Pages())
+		}
+		f.nav, f.navOrder, f.navPage = tree, item.Order, item.URL
+		f.frontier.rerank(func(pageURL string) int {
+			rank, _, _ := tree.Position(pageURL)
+			return rank
+		})
+	}
+}
+
+// navTree returns the sidebar found so far, or nil
+func (f *OptimizedFetcher) navTree() *NavTree {
+	f.navMutex.Lock()
+	defer f.navMutex.Unlock()
+	return f.nav
+}
+
+// writeResultsNav collects every page, then writes them in sidebar order
+// with headings nested the way the sidebar nests them. Categories without a
+// page of their own become plain headings, and pages the sidebar does not
+// list follow at the end in discovery order.
+func writeResultsNav(outputPath string, resultsChan <-chan *PageResult, nav func() *NavTree) error {
+	var results []*PageResult
+	pages := make(map[string]*PageResult) // Canonical key of every URL a page is known by
+	for result := range resultsChan {
+		if strings.TrimSpace(result.Content) == "" {
+			continue
+		}
+		results = append(results, result)
+		for _, known := range []string{result.URL, result.CanonicalURL, result.fetchedURL} {
+			if known != "" {
+				pages[canonicalKeyString(known)] = result
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Order < results[j].Order
+	})
+
+	tree := nav()
+	if tree == nil {
+		log.Printf("⚠️  Warning: No documentation sidebar found; pages are written in discovery order")
+	}
+
+	var sb strings.Builder
+	sb.WriteString(markdownHeader)
+
+	written := make(map[*PageResult]bool)
+	var render func(node *NavNode, depth int) string
+	render = func(node *NavNode, depth int) string {
+		level := min(depth+2, 6)
+		var out strings.Builder
+
+		page := pages[canonicalKeyString(node.URL)]
+		if node.URL != "" && page != nil && !written[page] {
+			written[page] = true
+			out.WriteString(formatPageSection(page, level, shiftHeadings(page.Content, level)))
+		}
+		var children strings.Builder
+		for _, child := range node.Children {
+			children.WriteString(render(child, depth+1))
+		}
+
+		// A category is only worth a heading if something under it was fetched
+		if out.Len() == 0 && children.Len() > 0 && node.Title != "" {
+			fmt.Fprintf(&out, "%s %s\n\n", strings.Repeat("#", level), node.Title)
+		}
+		out.WriteString(children.String())
+		return out.String()
+	}
+	if tree != nil {
+		for _, node := range tree.Roots {
+			sb.WriteString(render(node, 0))
+		}
+	}
+
+	for _, result := range results {
+		if !written[result] {
+			sb.WriteString(formatPageSection(result, 2, shiftHeadings(result.Content, 2)))
+		}
+	}
+
+	return os.WriteFile(outputPath, []byte(sb.String()), 0644)
+}
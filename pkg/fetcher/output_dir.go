@@ -0,0 +1,339 @@
+package fetcher
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// pageExtensions are stripped from the last path segment before adding .md
+var pageExtensions = map[string]bool{
+	".html": true, ".htm": true, ".xhtml": true, ".shtml": true,
+	".php": true, ".asp": true, ".aspx": true, ".jsp": true,
+}
+
+// dirPage is a page written to its own file in an output directory
+type dirPage struct {
+	Title string
+	URL   string
+	File  string // Slash-separated path relative to the output directory
+	Order int64
+}
+
+// dirWriter writes one Markdown file per page into a tree mirroring the site's
+// URLs (host/path/page.md), rewrites links between fetched pages to relative
+// file links once every page is known, and writes an index.md
+type dirWriter struct {
+	root  string
+	pages []*dirPage
+	files map[string]*dirPage // Canonical key of every URL a page is known by
+	taken map[string]bool     // Lowercased file paths in use, for case-insensitive filesystems
+}
+
+// writeResultsToDir writes each result to its own file under dir
+func writeResultsToDir(dir, baseURL string, resultsChan <-chan *PageResult) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	w := &dirWriter{
+		root:  dir,
+		files: make(map[string]*dirPage),
+		taken: map[string]bool{"index.md": true},
+	}
+	for result := range resultsChan {
+		if strings.TrimSpace(result.Content) == "" {
+			continue
+		}
+		if err := w.write(result); err != nil {
+			return err
+		}
+	}
+
+	// Links can only point at local files once the whole site has been fetched
+	if err := w.rewriteLinks(); err != nil {
+		return err
+	}
+	return w.writeIndex(baseURL)
+}
+
+// write saves a page under the path its URL maps to
+func (w *dirWriter) write(result *PageResult) error {
+	pageURL := result.CanonicalURL
+	if pageURL == "" {
+		pageURL = result.URL
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return err
+	}
+
+	page := &dirPage{
+		Title: result.Title,
+		URL:   pageURL,
+		File:  w.claim(pageFilePath(u)),
+		Order: result.Order,
+	}
+	w.pages = append(w.pages, page)
+	for _, known := range []string{result.URL, result.CanonicalURL} {
+		if known != "" {
+			w.files[canonicalKeyString(known)] = page
+		}
+	}
+
+	// Every error is returned, so a partly written directory never passes for a finished crawl
+	return writeFileAtomic(filepath.Join(w.root, filepath.FromSlash(page.File)), []byte(formatPageFile(result)))
+}
+
+// claim reserves a file path, numbering it if another page already has it
+func (w *dirWriter) claim(file string) string {
+	stem := strings.TrimSuffix(file, ".md")
+	for i := 2; w.taken[strings.ToLower(file)]; i++ {
+		file = fmt.Sprintf("%s-%d.md", stem, i)
+	}
+	w.taken[strings.ToLower(file)] = true
+	return file
+}
+
+// pageFilePath maps a page URL to a relative file path: directory URLs and
+// index pages become index.md, other pages take their last segment's name.
+// Query strings are folded into the file name.
+func pageFilePath(u *url.URL) string {
+	segments := []string{safePathSegment(u.Host)}
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+		segments = append(segments, safePathSegment(segment))
+	}
+
+	name := "index"
+	last := path.Base(u.Path)
+	if !strings.HasSuffix(u.Path, "/") && len(segments) > 1 && !indexFiles[strings.ToLower(last)] {
+		name = segments[len(segments)-1]
+		segments = segments[:len(segments)-1]
+		if ext := path.Ext(name); pageExtensions[strings.ToLower(ext)] {
+			name = strings.TrimSuffix(name, ext)
+		}
+	} else if indexFiles[strings.ToLower(last)] {
+		segments = segments[:len(segments)-1]
+	}
+
+	if u.RawQuery != "" {
+		query := safePathSegment(u.RawQuery)
+		if len(query) > 40 {
+			query = query[:40]
+		}
+		name += "-" + query
+	}
+	return strings.Join(append(segments, name+".md"), "/")
+}
+
+// safePathSegment keeps letters, digits, dots, dashes and underscores and
+// replaces anything else, so a URL can never escape the output directory
+func safePathSegment(segment string) string {
+	safe := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, segment)
+
+	safe = strings.Trim(safe, ".")
+	if len(safe) > 100 {
+		safe = safe[:100]
+	}
+	if safe == "" {
+		return "_"
+	}
+	return safe
+}
+
+// rewriteLinks points links between fetched pages at the local files
+func (w *dirWriter) rewriteLinks() error {
+	for _, page := range w.pages {
+		target := filepath.Join(w.root, filepath.FromSlash(page.File))
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return err
+		}
+
+		rewritten := rewriteMarkdownLinks(string(data), func(dest string) (string, bool) {
+			return w.localLink(page, dest)
+		})
+		if rewritten == string(data) {
+			continue
+		}
+		if err := writeFileAtomic(target, []byte(rewritten)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localLink returns the relative link from one page's file to the page dest
+// points at, keeping the fragment, or false if dest was not fetched
+func (w *dirWriter) localLink(from *dirPage, dest string) (string, bool) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	to, ok := w.files[canonicalKey(u)]
+	if !ok {
+		return "", false
+	}
+
+	fragment := ""
+	if u.Fragment != "" {
+		fragment = "#" + u.EscapedFragment()
+	}
+	if to == from && fragment != "" {
+		return fragment, true
+	}
+	return relativeFileLink(from.File, to.File) + fragment, true
+}
+
+// relativeFileLink is the slash-separated path to file "to" from the directory of file "from"
+func relativeFileLink(from, to string) string {
+	rel, err := filepath.Rel(filepath.Dir(filepath.FromSlash(from)), filepath.FromSlash(to))
+	if err != nil {
+		return to
+	}
+	return filepath.ToSlash(rel)
+}
+
+// rewriteMarkdownLinks passes every link destination outside code blocks to
+// rewrite and replaces the ones it returns true for; images are left alone
+func rewriteMarkdownLinks(content string, rewrite func(dest string) (string, bool)) string {
+	lines := strings.Split(content, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if fence != "" {
+			if closesCodeFence(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if marker := codeFenceMarker(trimmed); marker != "" {
+			fence = marker
+			continue
+		}
+
+		lines[i] = markdownLink.ReplaceAllStringFunc(line, func(link string) string {
+			m := markdownLink.FindStringSubmatch(link)
+			if m[1] != "" {
+				return link
+			}
+			dest := strings.TrimSuffix(strings.TrimPrefix(m[3], "<"), ">")
+			local, ok := rewrite(dest)
+			if !ok {
+				return link
+			}
+			return "[" + m[2] + "](" + local + ")"
+		})
+	}
+	return strings.Join(lines, "\n")
+}
+
+// codeFenceMarker returns the backtick or tilde run opening a fenced code block, or ""
+func codeFenceMarker(line string) string {
+	for _, c := range []string{"`", "~"} {
+		run := len(line) - len(strings.TrimLeft(line, c))
+		if run >= 3 {
+			return strings.Repeat(c, run)
+		}
+	}
+	return ""
+}
+
+// closesCodeFence reports whether a line closes the fenced code block opened
+// by fence: at least as long, the same character and nothing after it
+func closesCodeFence(line, fence string) bool {
+	marker := codeFenceMarker(line)
+	return marker != "" && marker[0] == fence[0] && len(marker) >= len(fence) && strings.TrimSpace(line[len(marker):]) == ""
+}
+
+// formatPageFile renders a page as a standalone Markdown file
+func formatPageFile(page *PageResult) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "# %s\n\n", page.Title)
+	fmt.Fprintf(&sb, "*Source: <%s> · Depth: %d", page.URL, page.Depth)
+	if page.Parent != "" {
+		fmt.Fprintf(&sb, " · Linked from: [%s](%s)", page.Parent, page.Parent)
+	}
+	sb.WriteString("*\n\n")
+	sb.WriteString(lowConfidenceNotice(page))
+	fmt.Fprintf(&sb, "%s\n", page.Content)
+
+	return sb.String()
+}
+
+// indexNode is a directory or page in the index tree
+type indexNode struct {
+	name     string
+	page     *dirPage
+	children map[string]*indexNode
+	order    int64 // Earliest discovery order below this node
+}
+
+// writeIndex writes index.md, listing the pages as a tree that follows the
+// URL hierarchy, each level in discovery order
+func (w *dirWriter) writeIndex(baseURL string) error {
+	root := &indexNode{children: make(map[string]*indexNode)}
+	for _, page := range w.pages {
+		node := root
+		parts := strings.Split(strings.TrimSuffix(page.File, ".md"), "/")
+		if parts[len(parts)-1] == "index" {
+			parts = parts[:len(parts)-1]
+		}
+		for _, part := range parts {
+			child, ok := node.children[part]
+			if !ok {
+				child = &indexNode{name: part, children: make(map[string]*indexNode), order: page.Order}
+				node.children[part] = child
+			}
+			child.order = min(child.order, page.Order)
+			node = child
+		}
+		node.page = page
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Documentation\n\n")
+	fmt.Fprintf(&sb, "Fetched from <%s> by DocFetch: %d pages.\n\n", baseURL, len(w.pages))
+	writeIndexNodes(&sb, root, 0)
+
+	return writeFileAtomic(filepath.Join(w.root, "index.md"), []byte(sb.String()))
+}
+
+// writeIndexNodes renders a node's children as a nested list
+func writeIndexNodes(sb *strings.Builder, node *indexNode, depth int) {
+	children := make([]*indexNode, 0, len(node.children))
+	for _, child := range node.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].order != children[j].order {
+			return children[i].order < children[j].order
+		}
+		return children[i].name < children[j].name
+	})
+
+	indent := strings.Repeat("  ", depth)
+	for _, child := range children {
+		if child.page != nil {
+			title := strings.Join(strings.Fields(child.page.Title), " ")
+			fmt.Fprintf(sb, "%s- [%s](%s)\n", indent, escapeInline(title), child.page.File)
+		} else {
+			fmt.Fprintf(sb, "%s- %s/\n", indent, escapeInline(child.name))
+		}
+		writeIndexNodes(sb, child, depth+1)
+	}
+}
@@ -0,0 +1,51 @@
+package fetcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteResultsToDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		block   string // File created first where the writer needs a directory
+		wantErr bool
+	}{
+		{name: "writes pages and index"},
+		{name: "page directory blocked by a file", block: "docs.example.com/guide", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.block != "" {
+				blocked := filepath.Join(dir, filepath.FromSlash(tt.block))
+				if err := os.MkdirAll(filepath.Dir(blocked), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(blocked, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			results := make(chan *PageResult, 2)
+			results <- &PageResult{URL: "https://docs.example.com/", Title: "Home", Content: "See [intro](https://docs.example.com/guide/intro)."}
+			results <- &PageResult{URL: "https://docs.example.com/guide/intro", Title: "Intro", Content: "Welcome", Order: 1}
+			close(results)
+
+			err := writeResultsToDir(dir, "https://docs.example.com/", results)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeResultsToDir() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			for _, file := range []string{"index.md", "docs.example.com/index.md", "docs.example.com/guide/intro.md"} {
+				if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(file))); err != nil {
+					t.Errorf("%s not written: %v", file, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package fetcher
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// siteProfile describes how a documentation generator lays out its pages:
+// how to recognize it and where its content, chrome and navigation live
+type siteProfile struct {
+	Name string
+
+	// Fingerprints; any one match identifies the generator
+	Generators  []string // Substrings of <meta name="generator">, lowercase
+	Hosts       []string // Exact hostnames
+	BodyClasses []string // Class prefixes on <html> or <body>
+	Markers     []string // Selectors only this generator produces
+	Assets      []string // Substrings of script src or stylesheet href
+
+	Content []string // Selectors for the main content, tried in order
+	Exclude []string // Selectors removed from the content (permalinks, edit links, pagers...)
+	Nav     []string // Selectors for the documentation navigation (sidebar)
+}
+
+// siteProfiles is the registry of known documentation generators, checked in order
+var siteProfiles = []*siteProfile{
+	{
+		Name:       "docusaurus",
+		Generators: []string{"docusaurus"},
+		Markers:    []string{"#__docusaurus", ".theme-doc-markdown"},
+		Assets:     []string{"/assets/js/runtime~main"},
+		Content:    []string{".theme-doc-markdown", "article .markdown", "main article"},
+		Exclude:    []string{".theme-doc-breadcrumbs", ".theme-doc-toc-mobile", ".theme-doc-footer", ".theme-doc-version-badge", ".theme-edit-this-page", ".theme-last-updated", ".pagination-nav", ".hash-link"},
+		Nav:        []string{".theme-doc-sidebar-menu", "nav.menu"},
+	},
+	{
+		Name:       "mkdocs-material",
+		Generators: []string{"mkdocs-material"},
+		Markers:    []string{".md-container .md-content"},
+		Assets:     []string{"assets/javascripts/bundle."},
+		Content:    []string{"article.md-content__inner", ".md-content"},
+		Exclude:    []string{".md-content__button", ".md-source-file", ".md-feedback", ".headerlink", ".md-clipboard"},
+		Nav:        []string{"nav.md-nav--primary"},
+	},
+	{
+		Name:       "sphinx",
+		Generators: []string{"sphinx", "docutils"},
+		Markers:    []string{".wy-nav-content", "div.sphinxsidebar", ".bd-article", "div[itemprop='articleBody']"},
+		Assets:     []string{"_static/doctools.js", "_static/documentation_options.js", "readthedocs"},
+		Content:    []string{"div[itemprop='articleBody']", ".bd-article", "article[role='main']", "div.body", "div.document"},
+		Exclude:    []string{".headerlink", ".rst-footer-buttons", ".wy-breadcrumbs", "div.related", ".sphinxsidebar", "#searchbox", ".prev-next-area", ".copybtn"},
+		Nav:        []string{".wy-menu-vertical", ".bd-docs-nav", ".sidebar-tree", ".sphinxsidebarwrapper"},
+	},
+	{
+		Name:       "gitbook",
+		Generators: []string{"gitbook"},
+		Markers:    []string{".book-summary", ".markdown-section"},
+		Assets:     []string{"gitbook.com", "/gitbook/"},
+		Content:    []string{"section.markdown-section", "main [data-testid='page.contentEditor']", "main"},
+		Exclude:    []string{".navigation", "[data-testid='page-footer-navigation']", ".page-footer"},
+		Nav:        []string{".book-summary", "aside nav", "[data-testid='table-of-contents']"},
+	},
+	{
+		Name:       "vitepress",
+		Generators: []string{"vitepress"},
+		Markers:    []string{"#VPContent", ".VPDoc"},
+		Content:    []string{".vp-doc", ".VPDoc .content-container"},
+		Exclude:    []string{".header-anchor", ".edit-info", ".prev-next", ".VPDocFooter", ".VPDocAside"},
+		Nav:        []string{"#VPSidebarNav", ".VPSidebar nav"},
+	},
+	{
+		Name:        "docsy",
+		BodyClasses: []string{"td-"},
+		Markers:     []string{".td-content", "#td-sidebar-menu"},
+		Content:     []string{".td-content"},
+		Exclude:     []string{".td-page-meta", ".td-toc", ".td-breadcrumbs", ".feedback--title", ".feedback--answer", ".td-content > .d-print-none"},
+		Nav:         []string{"#td-sidebar-menu", ".td-sidebar-nav"},
+	},
+	{
+		Name:       "javadoc",
+		Generators: []string{"javadoc"},
+		Markers:    []string{".contentContainer", ".flex-content main"},
+		Assets:     []string{"script-dir/"},
+		Content:    []string{".flex-content main", "main[role='main']", ".contentContainer"},
+		Exclude:    []string{".top-nav", ".sub-nav", ".topNav", ".subNav", ".bottomNav", ".legal-copy", ".skip-nav"},
+		Nav:        []string{".top-nav", ".topNav"},
+	},
+	{
+		Name:        "rustdoc",
+		Generators:  []string{"rustdoc"},
+		BodyClasses: []string{"rustdoc"},
+		Content:     []string{"#main-content"},
+		Exclude:     []string{".out-of-band", "#copy-path", "a.anchor", ".src", ".srclink", "rustdoc-toolbar", ".search-form"},
+		Nav:         []string{".sidebar-elems", "nav.sidebar"},
+	},
+	{
+		Name:    "pkg.go.dev",
+		Hosts:   []string{"pkg.go.dev"},
+		Markers: []string{".UnitDoc", ".Documentation-content"},
+		Assets:  []string{"/static/frontend/"},
+		Content: []string{".Documentation-content", ".UnitDoc", "main"},
+		Exclude: []string{".Documentation-index", ".Documentation-sinceVersion", ".UnitMeta", ".UnitOutline", ".go-Header", ".go-Footer"},
+		Nav:     []string{".UnitOutline"},
+	},
+}
+
+// detectProfile fingerprints the generator that built a page, or returns nil.
+// The generator meta tag is trusted first, then classes, markers and asset paths.
+func detectProfile(doc *goquery.Document) *siteProfile {
+	generator := strings.ToLower(doc.Find("meta[name='generator']").AttrOr("content", ""))
+	if generator != "" {
+		for _, profile := range siteProfiles {
+			for _, name := range profile.Generators {
+				if strings.Contains(generator, name) {
+					return profile
+				}
+			}
+		}
+	}
+
+	host := ""
+	if doc.Url != nil {
+		host = doc.Url.Hostname()
+	}
+	classes := strings.Fields(doc.Find("html").AttrOr("class", "") + " " + doc.Find("body").AttrOr("class", ""))
+
+	var assets []string
+	doc.Find("script[src], link[rel='stylesheet'][href]").Each(func(i int, s *goquery.Selection) {
+		assets = append(assets, s.AttrOr("src", s.AttrOr("href", "")))
+	})
+
+	for _, profile := range siteProfiles {
+		for _, h := range profile.Hosts {
+			if host == h {
+				return profile
+			}
+		}
+		for _, prefix := range profile.BodyClasses {
+			for _, class := range classes {
+				if strings.HasPrefix(class, prefix) {
+					return profile
+				}
+			}
+		}
+		for _, marker := range profile.Markers {
+			if doc.Find(marker).Length() > 0 {
+				return profile
+			}
+		}
+		for _, asset := range profile.Assets {
+			for _, src := range assets {
+				if strings.Contains(src, asset) {
+					return profile
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// profileNoise is removed from profile content on top of the profile's own exclusions
+const profileNoise = "script, style, noscript, template, form, iframe, button"
+
+// extractWithProfile pulls the content out with the profile's selectors,
+// returning "" if none of them match anything useful
+func extractWithProfile(doc *goquery.Document, profile *siteProfile, converter *markdownConverter) (content, selector string) {
+	for _, selector := range profile.Content {
+		el := doc.Find(selector).First()
+		if el.Length() == 0 {
+			continue
+		}
+
+		clone := el.Clone()
+		clone.Find(profileNoise).Remove()
+		for _, exclude := range profile.Exclude {
+			clone.Find(exclude).Remove()
+		}
+		for _, nav := range profile.Nav {
+			clone.Find(nav).Remove()
+		}
+
+		if content := converter.convert(clone.Get(0)); content != "" {
+			return content, selector
+		}
+	}
+	return "", ""
+}
+
+// navLinks returns the links in a profile's navigation, in sidebar order
+func navLinks(doc *goquery.Document, profile *siteProfile) *goquery.Selection {
+	for _, selector := range profile.Nav {
+		if links := doc.Find(selector).First().Find("a[href]"); links.Length() > 0 {
+			return links
+		}
+	}
+	return nil
+}
+
+// findProfile looks up a profile by name, returning nil for "" or unknown names
+func findProfile(name string) *siteProfile {
+	for _, profile := range siteProfiles {
+		if profile.Name == name {
+			return profile
+		}
+	}
+	return nil
+}
+
+// recordProfile counts a page towards the profile it was extracted with
+func (f *OptimizedFetcher) recordProfile(name string) {
+	if name == "" {
+		name = "none"
+	}
+
+	f.profilesMutex.Lock()
+	f.profiles[name]++
+	f.profilesMutex.Unlock()
+}
+
+// formatCounts renders counts most common first, e.g. "sphinx (41), none (2)"
+func formatCounts(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s (%d)", name, counts[name])
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,184 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minAdaptiveRate is the slowest the adaptive limiter will back off to (requests per second)
+	minAdaptiveRate = 0.1
+	// adaptiveRecovery is the fraction of the target rate regained after each successful response
+	adaptiveRecovery = 0.05
+)
+
+// rateLimiter throttles requests with a token bucket per host
+type rateLimiter struct {
+	rate     float64
+	burst    int
+	adaptive bool
+	mu       sync.Mutex
+	hosts    map[string]*hostBucket
+}
+
+// hostBucket is the token bucket for a single host
+type hostBucket struct {
+	mu      sync.Mutex
+	target  float64 // Rate to recover to: the configured rate, capped by Crawl-delay
+	rate    float64 // Current rate; below target while backing off
+	burst   float64
+	tokens  float64
+	last    time.Time // When tokens were last refilled; in the future while paused
+	delayed bool      // Crawl-delay already applied
+}
+
+// newRateLimiter creates a limiter allowing rate requests per second per host with the given burst.
+// In adaptive mode the host's rate halves on 429/503 responses and recovers on success.
+func newRateLimiter(rate float64, burst int, adaptive bool) *rateLimiter {
+	return &rateLimiter{
+		rate:     rate,
+		burst:    burst,
+		adaptive: adaptive,
+		hosts:    make(map[string]*hostBucket),
+	}
+}
+
+// bucket returns the host's bucket, creating a full one on first use
+func (rl *rateLimiter) bucket(host string) *hostBucket {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	b, exists := rl.hosts[host]
+	if !exists {
+		b = &hostBucket{
+			target: rl.rate,
+			rate:   rl.rate,
+			burst:  float64(rl.burst),
+			tokens: float64(rl.burst),
+			last:   time.Now(),
+		}
+		rl.hosts[host] = b
+	}
+	return b
+}
+
+// wait blocks until the host's bucket has a token for one request
+func (rl *rateLimiter) wait(ctx context.Context, host string) error {
+	b := rl.bucket(host)
+
+	b.mu.Lock()
+	now := time.Now()
+	if now.After(b.last) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+	b.tokens--
+
+	// Reserve the token now; a negative balance is paid back by waiting
+	delay := b.last.Sub(now)
+	if b.tokens < 0 {
+		delay += time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("rate limit wait interrupted: %w", ctx.Err())
+	}
+}
+
+// setCrawlDelay caps the host at one request per delay, as asked by its robots.txt
+func (rl *rateLimiter) setCrawlDelay(host string, delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+
+	b := rl.bucket(host)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.delayed {
+		return
+	}
+	b.delayed = true
+
+	if limit := 1 / delay.Seconds(); limit < b.target {
+		b.target = limit
+		b.rate = math.Min(b.rate, limit)
+		b.burst = 1
+		b.tokens = math.Min(b.tokens, 1)
+	}
+}
+
+// observe adjusts the host's rate after a response. It reports whether the
+// server asked us to slow down (429 or 503).
+func (rl *rateLimiter) observe(host string, resp *http.Response) bool {
+	throttled := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+	if !rl.adaptive {
+		return throttled
+	}
+
+	b := rl.bucket(host)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !throttled {
+		if resp.StatusCode < 400 && b.rate < b.target {
+			b.rate = math.Min(b.target, b.rate+b.target*adaptiveRecovery)
+		}
+		return false
+	}
+
+	b.rate = math.Max(minAdaptiveRate, b.rate/2)
+
+	// Pause the host until Retry-After, or for one interval at the new rate
+	pause := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if pause <= 0 {
+		pause = time.Duration(float64(time.Second) / b.rate)
+	}
+	if until := time.Now().Add(pause); until.After(b.last) {
+		b.last = until
+		b.tokens = 1
+	}
+
+	log.Printf("🐢 %s returned %d; slowing to %.2f req/s and pausing %v", host, resp.StatusCode, b.rate, pause.Round(time.Millisecond))
+	return true
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if when, err := http.ParseTime(value); err == nil && when.After(now) {
+		return when.Sub(now)
+	}
+	return 0
+}
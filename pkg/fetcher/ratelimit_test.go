@@ -0,0 +1,109 @@
+package fetcher
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBucketPerHost(t *testing.T) {
+	// A cancelled context makes any wait fail at once, so only free tokens succeed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rl := newRateLimiter(0.001, 2, false)
+	for i := 0; i < 2; i++ {
+		if err := rl.wait(ctx, "a.example.com"); err != nil {
+			t.Fatalf("request %d within the burst waited: %v", i+1, err)
+		}
+	}
+	if err := rl.wait(ctx, "a.example.com"); err == nil {
+		t.Error("request past the burst did not wait")
+	}
+	if err := rl.wait(ctx, "b.example.com"); err != nil {
+		t.Errorf("another host shared the bucket: %v", err)
+	}
+}
+
+func TestRateLimiterAdaptive(t *testing.T) {
+	tests := []struct {
+		name          string
+		adaptive      bool
+		status        int
+		retryAfter    string
+		wantThrottled bool
+		wantRate      float64
+		wantPause     time.Duration // Minimum time the host is paused for (0 = not checked)
+	}{
+		{name: "success at full rate", adaptive: true, status: http.StatusOK, wantRate: 4},
+		{name: "429 halves the rate", adaptive: true, status: http.StatusTooManyRequests, wantThrottled: true, wantRate: 2, wantPause: 400 * time.Millisecond},
+		{name: "503 halves the rate", adaptive: true, status: http.StatusServiceUnavailable, wantThrottled: true, wantRate: 2, wantPause: 400 * time.Millisecond},
+		{name: "Retry-After sets the pause", adaptive: true, status: http.StatusTooManyRequests, retryAfter: "30", wantThrottled: true, wantRate: 2, wantPause: 29 * time.Second},
+		{name: "fixed rate reports but keeps its rate", status: http.StatusTooManyRequests, wantThrottled: true, wantRate: 4},
+		{name: "server error is not throttling", adaptive: true, status: http.StatusInternalServerError, wantRate: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := newRateLimiter(4, 4, tt.adaptive)
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+
+			if got := rl.observe("docs.example.com", resp); got != tt.wantThrottled {
+				t.Errorf("observe() = %v, want %v", got, tt.wantThrottled)
+			}
+			b := rl.bucket("docs.example.com")
+			if b.rate != tt.wantRate {
+				t.Errorf("rate = %v, want %v", b.rate, tt.wantRate)
+			}
+			if pause := time.Until(b.last); tt.wantPause > 0 && pause < tt.wantPause {
+				t.Errorf("paused for %v, want at least %v", pause, tt.wantPause)
+			}
+		})
+	}
+}
+
+func TestRateLimiterRecovers(t *testing.T) {
+	rl := newRateLimiter(4, 4, true)
+	rl.observe("docs.example.com", &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}})
+	for i := 0; i < 100; i++ {
+		rl.observe("docs.example.com", &http.Response{StatusCode: http.StatusOK})
+	}
+	if rate := rl.bucket("docs.example.com").rate; rate != 4 {
+		t.Errorf("rate after recovering = %v, want 4", rate)
+	}
+}
+
+func TestRateLimiterCrawlDelay(t *testing.T) {
+	rl := newRateLimiter(4, 4, false)
+	rl.setCrawlDelay("docs.example.com", 2*time.Second)
+	b := rl.bucket("docs.example.com")
+	if b.target != 0.5 || b.rate != 0.5 || b.burst != 1 {
+		t.Errorf("after Crawl-delay: target %v, rate %v, burst %v; want 0.5, 0.5, 1", b.target, b.rate, b.burst)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{" 3 ", 3 * time.Second},
+		{"-5", 0},
+		{"soon", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Hour).Format(http.TimeFormat), 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
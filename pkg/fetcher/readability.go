@@ -0,0 +1,308 @@
+package fetcher
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// lowConfidence is the extraction confidence below which a page is flagged in the output
+const lowConfidence = 0.4
+
+// Class and id patterns used to weigh candidates, after Mozilla Readability
+var (
+	unlikelyCandidate = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|consent|cookie|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|newsletter|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|supplemental|ad-break|agegate|pagination|pager|popup|yom-remote`)
+	maybeCandidate    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow|doc`)
+	positiveWeight    = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story|doc|markdown|prose`)
+	negativeWeight    = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|cookie|foot|footer|footnote|gdpr|masthead|media|meta|menu|nav|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+)
+
+// unlikelyRoles are ARIA roles that never hold the main content
+var unlikelyRoles = map[string]bool{
+	"menu": true, "menubar": true, "complementary": true, "navigation": true,
+	"alert": true, "alertdialog": true, "dialog": true, "banner": true, "contentinfo": true,
+}
+
+// readabilityNoise is removed before scoring
+const readabilityNoise = "script, style, noscript, template, iframe, form, nav, footer, aside, button, svg, [hidden], [aria-hidden='true']"
+
+// scoredTags are the elements whose text is scored and credited to their ancestors
+var scoredTags = map[atom.Atom]bool{
+	atom.P: true, atom.Pre: true, atom.Td: true, atom.Section: true, atom.Li: true,
+	atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+}
+
+// readabilityExtract scores the page the way Mozilla Readability does: text
+// blocks credit their ancestors by length and comma count, candidates are
+// weighed by tag, class and id and discounted by link density, and the best
+// candidate is merged with siblings that look like part of the same article.
+// It returns the content container and the confidence of the pick.
+func readabilityExtract(doc *goquery.Document) (*html.Node, float64) {
+	body := doc.Find("body").First()
+	if body.Length() == 0 {
+		return nil, 0
+	}
+
+	// Score a copy; the page's links are still needed for crawling
+	root := body.Clone()
+	root.Find(readabilityNoise).Remove()
+	root.Find("*").Each(func(i int, s *goquery.Selection) {
+		if isUnlikelyCandidate(s.Get(0)) {
+			s.Remove()
+		}
+	})
+	rootNode := root.Get(0)
+
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if scoredTags[c.DataAtom] || (c.DataAtom == atom.Div && !hasBlockDescendant(c)) {
+				candidates = scoreBlock(c, rootNode, scores, candidates)
+			}
+			walk(c)
+		}
+	}
+	walk(rootNode)
+
+	// Link-heavy candidates (link lists, footers, tag clouds) lose most of their score
+	var top, runnerUp *html.Node
+	final := make(map[*html.Node]float64, len(candidates))
+	for _, candidate := range candidates {
+		final[candidate] = scores[candidate] * (1 - linkDensity(candidate))
+		if top == nil || final[candidate] > final[top] {
+			top = candidate
+		}
+	}
+	if top == nil {
+		return nil, 0
+	}
+
+	// The runner-up is the best candidate that is not part of the winner
+	for _, candidate := range candidates {
+		if isAncestor(candidate, top) || isAncestor(top, candidate) || candidate == top {
+			continue
+		}
+		if runnerUp == nil || final[candidate] > final[runnerUp] {
+			runnerUp = candidate
+		}
+	}
+
+	container := mergeSiblings(top, final)
+
+	margin := 1.0
+	if runnerUp != nil && final[top] > 0 {
+		margin = 1 - final[runnerUp]/final[top]
+	}
+	return container, contentConfidence(container, margin)
+}
+
+// isUnlikelyCandidate reports whether an element looks like page chrome
+func isUnlikelyCandidate(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Body, atom.A, atom.Main, atom.Article, atom.Table, atom.Tbody, atom.Tr, atom.Td, atom.Th, atom.Pre, atom.Code:
+		return false
+	}
+	if unlikelyRoles[getAttr(n, "role")] {
+		return true
+	}
+
+	// Keep anything inside the article or a code block
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.DataAtom == atom.Article || p.DataAtom == atom.Main || p.DataAtom == atom.Pre {
+			return false
+		}
+	}
+
+	match := getAttr(n, "class") + " " + getAttr(n, "id")
+	return unlikelyCandidate.MatchString(match) && !maybeCandidate.MatchString(match)
+}
+
+// scoreBlock credits a text block's score to its parent (full), grandparent
+// (half) and further ancestors (a third per level), up to five levels
+func scoreBlock(n, root *html.Node, scores map[*html.Node]float64, candidates []*html.Node) []*html.Node {
+	text := innerText(n)
+	length := utf8.RuneCountInString(text)
+	if length < 25 {
+		return candidates
+	}
+
+	score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，"))
+	score += float64(min(length/100, 3))
+
+	level := 0
+	for ancestor := n.Parent; ancestor != nil && level < 5; ancestor = ancestor.Parent {
+		if ancestor.Type != html.ElementNode {
+			break
+		}
+		if _, ok := scores[ancestor]; !ok {
+			scores[ancestor] = initialScore(ancestor)
+			candidates = append(candidates, ancestor)
+		}
+
+		divider := 1.0
+		if level == 1 {
+			divider = 2
+		} else if level > 1 {
+			divider = float64(level * 3)
+		}
+		scores[ancestor] += score / divider
+
+		if ancestor == root {
+			break
+		}
+		level++
+	}
+	return candidates
+}
+
+// initialScore weighs a candidate by its tag, class and id
+func initialScore(n *html.Node) float64 {
+	score := 0.0
+	switch n.DataAtom {
+	case atom.Div, atom.Article, atom.Main:
+		score += 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score += 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score -= 5
+	}
+
+	for _, attr := range []string{getAttr(n, "class"), getAttr(n, "id")} {
+		if attr == "" {
+			continue
+		}
+		if negativeWeight.MatchString(attr) {
+			score -= 25
+		}
+		if positiveWeight.MatchString(attr) {
+			score += 25
+		}
+	}
+	return score
+}
+
+// mergeSiblings gathers the top candidate and the siblings that score well
+// enough, or read like prose, into one container
+func mergeSiblings(top *html.Node, final map[*html.Node]float64) *html.Node {
+	if top.Parent == nil {
+		return top
+	}
+	container := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+
+	threshold := max(10, final[top]*0.2)
+	var keep []*html.Node
+	for sibling := top.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling == top {
+			keep = append(keep, sibling)
+			continue
+		}
+		if sibling.Type != html.ElementNode {
+			continue
+		}
+
+		if score, ok := final[sibling]; ok && score >= threshold {
+			keep = append(keep, sibling)
+			continue
+		}
+		if sibling.DataAtom == atom.P {
+			text := innerText(sibling)
+			density := linkDensity(sibling)
+			length := utf8.RuneCountInString(text)
+			if length > 80 && density < 0.25 || length > 0 && density == 0 && strings.Contains(text, ". ") {
+				keep = append(keep, sibling)
+			}
+		}
+	}
+
+	for _, n := range keep {
+		n.Parent.RemoveChild(n)
+		container.AppendChild(n)
+	}
+	return container
+}
+
+// contentConfidence rates extracted content from 0 to 1 by how much prose it
+// holds, how many paragraphs and, for scored extraction, how clearly it beat
+// the runner-up (margin; 1 when there was no competition), scaled down by
+// the share of its text that is links
+func contentConfidence(n *html.Node, margin float64) float64 {
+	if n == nil {
+		return 0
+	}
+
+	length := float64(utf8.RuneCountInString(innerText(n)))
+	paragraphs := 0
+	var count func(*html.Node)
+	count = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && (c.DataAtom == atom.P || c.DataAtom == atom.Pre) && utf8.RuneCountInString(innerText(c)) >= 25 {
+				paragraphs++
+			}
+			count(c)
+		}
+	}
+	count(n)
+
+	margin = max(0, min(margin, 1))
+	confidence := 0.45*min(length/1500, 1) +
+		0.3*min(float64(paragraphs)/5, 1) +
+		0.25*margin
+
+	// Text that is mostly links is navigation, however long it is
+	confidence *= 1 - linkDensity(n)
+	return float64(int(confidence*100+0.5)) / 100
+}
+
+// linkDensity is the share of an element's text that sits inside links
+func linkDensity(n *html.Node) float64 {
+	total := utf8.RuneCountInString(innerText(n))
+	if total == 0 {
+		return 0
+	}
+
+	linked := 0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.DataAtom == atom.A {
+				// Same-page anchors are navigation within the article, not away from it
+				weight := 1.0
+				if strings.HasPrefix(getAttr(c, "href"), "#") {
+					weight = 0.3
+				}
+				linked += int(float64(utf8.RuneCountInString(innerText(c))) * weight)
+				continue
+			}
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return float64(linked) / float64(total)
+}
+
+// innerText returns an element's text with whitespace collapsed
+func innerText(n *html.Node) string {
+	return strings.Join(strings.Fields(textContent(n)), " ")
+}
+
+// isAncestor reports whether a is an ancestor of n
+func isAncestor(a, n *html.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p == a {
+			return true
+		}
+	}
+	return false
+}
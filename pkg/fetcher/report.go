@@ -0,0 +1,192 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ExtractionReport is the machine-readable record of how every page's content was found
+type ExtractionReport struct {
+	BaseURL     string        `json:"base_url"`
+	GeneratedAt *time.Time    `json:"generated_at,omitempty"` // Omitted in reproducible output
+	Summary     ReportSummary `json:"summary"`
+	Pages       []PageReport  `json:"pages"` // In crawl discovery order
+}
+
+// ReportSummary totals an extraction report
+type ReportSummary struct {
+	Pages             int            `json:"pages"`
+	Strategies        map[string]int `json:"strategies"`     // Pages per strategy, without the selector
+	Fallbacks         int            `json:"fallbacks"`      // Pages only readability scoring or the whole body could extract
+	Failed            int            `json:"failed"`         // Pages where no strategy found content
+	LowConfidence     int            `json:"low_confidence"` // Pages flagged in the output
+	AverageConfidence float64        `json:"average_confidence"`
+}
+
+// PageReport records how one page's content was extracted
+type PageReport struct {
+	URL           string  `json:"url"`
+	Title         string  `json:"title,omitempty"`
+	Profile       string  `json:"profile,omitempty"`
+	Strategy      string  `json:"strategy"`       // Strategy and selector, e.g. "semantic:main" ("none" = nothing found)
+	ContentLength int     `json:"content_length"` // Characters of Markdown
+	LinkDensity   float64 `json:"link_density"`   // Share of the text that is link text
+	Confidence    float64 `json:"confidence"`
+	Fallback      bool    `json:"fallback"`
+
+	order int64
+}
+
+// newPageReport describes a page's extraction
+func newPageReport(pageURL, title string, extracted extraction, order int64) PageReport {
+	strategy := extracted.Strategy
+	if extracted.Content == "" {
+		strategy = "none"
+	}
+
+	return PageReport{
+		URL:           pageURL,
+		Title:         title,
+		Profile:       extracted.Profile,
+		Strategy:      strategy,
+		ContentLength: utf8.RuneCountInString(extracted.Content),
+		LinkDensity:   markdownLinkDensity(extracted.Content),
+		Confidence:    extracted.Confidence,
+		Fallback:      isFallbackStrategy(strategy),
+		order:         order,
+	}
+}
+
+// strategyName strips the selector from a reported strategy, e.g. "semantic:main" -> "semantic"
+func strategyName(strategy string) string {
+	name, _, _ := strings.Cut(strategy, ":")
+	return name
+}
+
+// isFallbackStrategy reports whether content only came from the generic last resorts
+func isFallbackStrategy(strategy string) bool {
+	switch strategyName(strategy) {
+	case StrategyReadability, StrategyBody, "none":
+		return true
+	}
+	return false
+}
+
+// markdownLink matches a Markdown link or image, capturing the "!", the text and the destination
+var markdownLink = regexp.MustCompile(`(!?)\[((?:[^\[\]]|\[[^\]]*\])*)\]\((<[^>]*>|[^)\s]*)(?: "[^"]*")?\)`)
+
+// markdownLinkDensity is the share of the visible Markdown text that is link text
+func markdownLinkDensity(content string) float64 {
+	linked := 0
+	visible := markdownLink.ReplaceAllStringFunc(content, func(link string) string {
+		m := markdownLink.FindStringSubmatch(link)
+		if m[1] == "" {
+			linked += utf8.RuneCountInString(strings.Join(strings.Fields(m[2]), " "))
+		}
+		return m[2]
+	})
+
+	total := utf8.RuneCountInString(strings.Join(strings.Fields(visible), " "))
+	if total == 0 {
+		return 0
+	}
+	return float64(int(float64(linked)/float64(total)*100+0.5)) / 100
+}
+
+// recordExtraction adds a page to the extraction report
+func (f *OptimizedFetcher) recordExtraction(page PageReport) {
+	f.reportsMutex.Lock()
+	f.reports = append(f.reports, page)
+	f.reportsMutex.Unlock()
+}
+
+// extractionReport assembles the report for every page processed so far
+func (f *OptimizedFetcher) extractionReport() *ExtractionReport {
+	f.reportsMutex.Lock()
+	pages := append([]PageReport{}, f.reports...)
+	f.reportsMutex.Unlock()
+
+	if f.config.Reproducible {
+		// Discovery order depends on worker scheduling; pages without content are not in the output order at all
+		for i := range pages {
+			pages[i].order = f.stableOrderOf(pages[i].URL, int64(len(pages)))
+		}
+		sort.SliceStable(pages, func(i, j int) bool {
+			if pages[i].order != pages[j].order {
+				return pages[i].order < pages[j].order
+			}
+			return pages[i].URL < pages[j].URL
+		})
+	}
+
+	report := buildExtractionReport(f.config.BaseURL, pages)
+	if f.config.Reproducible {
+		report.GeneratedAt = nil
+	}
+	return report
+}
+
+// buildExtractionReport orders pages by discovery and totals them
+func buildExtractionReport(baseURL string, pages []PageReport) *ExtractionReport {
+	sort.SliceStable(pages, func(i, j int) bool { return pages[i].order < pages[j].order })
+
+	summary := ReportSummary{Pages: len(pages), Strategies: make(map[string]int)}
+	total := 0.0
+	for _, page := range pages {
+		summary.Strategies[strategyName(page.Strategy)]++
+		total += page.Confidence
+		if page.Fallback {
+			summary.Fallbacks++
+		}
+		if page.Strategy == "none" {
+			summary.Failed++
+		} else if page.Confidence < lowConfidence {
+			summary.LowConfidence++
+		}
+	}
+	if len(pages) > 0 {
+		summary.AverageConfidence = float64(int(total/float64(len(pages))*100+0.5)) / 100
+	}
+
+	now := time.Now().UTC()
+	return &ExtractionReport{
+		BaseURL:     baseURL,
+		GeneratedAt: &now,
+		Summary:     summary,
+		Pages:       pages,
+	}
+}
+
+// WriteExtractionReport saves a report as indented JSON
+func WriteExtractionReport(report *ExtractionReport, path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// logExtractionFallbacks prints a table of the pages that needed a fallback strategy
+func logExtractionFallbacks(report *ExtractionReport) {
+	summary := report.Summary
+	log.Printf("   🧭 Extraction strategies: %s", formatCounts(summary.Strategies))
+	log.Printf("   🪂 Extraction fallbacks: %d of %d pages (%d failed, %d low confidence, average confidence %.2f)",
+		summary.Fallbacks, summary.Pages, summary.Failed, summary.LowConfidence, summary.AverageConfidence)
+	if summary.Fallbacks == 0 {
+		return
+	}
+
+	log.Printf("      %-12s %5s %6s %8s  %s", "STRATEGY", "CONF", "LINKS", "LENGTH", "URL")
+	for _, page := range report.Pages {
+		if !page.Fallback {
+			continue
+		}
+		log.Printf("      %-12s %5.2f %6.2f %8d  %s", page.Strategy, page.Confidence, page.LinkDensity, page.ContentLength, page.URL)
+	}
+}
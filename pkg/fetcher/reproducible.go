@@ -0,0 +1,169 @@
+package fetcher
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// trailingSpace matches whitespace at the end of a line
+var trailingSpace = regexp.MustCompile(`[ \t]+\n`)
+
+// stableResults holds every page until the crawl ends, then passes them on
+// in an order that does not depend on worker scheduling: pages listed in the
+// site's sidebar first, in sidebar order, then the rest in the order a
+// single-threaded breadth-first crawl would have found them. Depth and
+// parent are recomputed the same way, fetch times are dropped, whitespace
+// is normalized and pages are named by their canonical URL rather than
+// whichever duplicate URL a worker happened to reach first, so two runs over
+// an unchanged site write identical output.
+func (f *OptimizedFetcher) stableResults(in <-chan *PageResult) <-chan *PageResult {
+	out := make(chan *PageResult)
+	go func() {
+		defer close(out)
+
+		var results []*PageResult
+		for result := range in {
+			results = append(results, result)
+		}
+		f.orderStable(results)
+
+		for _, result := range results {
+			out <- result
+		}
+	}()
+	return out
+}
+
+// orderStable sorts results into their reproducible order and renumbers them
+func (f *OptimizedFetcher) orderStable(results []*PageResult) {
+	pages := make(map[string]*PageResult) // Canonical key of every URL a page is known by
+	for _, result := range results {
+		for _, known := range []string{result.URL, result.CanonicalURL} {
+			if known != "" {
+				pages[canonicalKeyString(known)] = result
+			}
+		}
+	}
+
+	// Seeds were queued one after another before any worker started, so their order is stable
+	var queue []*PageResult
+	for _, result := range results {
+		if result.Depth == 0 {
+			queue = append(queue, result)
+		}
+	}
+	sort.SliceStable(queue, func(i, j int) bool { return queue[i].Order < queue[j].Order })
+
+	// Replay the crawl breadth-first over the links each page was found to have
+	reached := make(map[*PageResult]bool)
+	for _, seed := range queue {
+		reached[seed] = true
+		seed.Parent = ""
+	}
+	for i := 0; i < len(queue); i++ {
+		page := queue[i]
+		for _, link := range page.Links {
+			next := pages[canonicalKeyString(link)]
+			if next == nil || reached[next] {
+				continue
+			}
+			reached[next] = true
+			next.Depth = page.Depth + 1
+			next.Parent = stableURL(page)
+			queue = append(queue, next)
+		}
+	}
+
+	// Pages only reachable through pages without content keep their depth
+	var rest []*PageResult
+	for _, result := range results {
+		if !reached[result] {
+			if parent := pages[canonicalKeyString(result.Parent)]; parent != nil {
+				result.Parent = stableURL(parent)
+			}
+			rest = append(rest, result)
+		}
+	}
+	sort.SliceStable(rest, func(i, j int) bool {
+		if rest[i].Depth != rest[j].Depth {
+			return rest[i].Depth < rest[j].Depth
+		}
+		return stableURL(rest[i]) < stableURL(rest[j])
+	})
+	ordered := append(queue, rest...)
+
+	nav := f.navTree()
+	sort.SliceStable(ordered, func(i, j int) bool {
+		rankI, _, inNavI := nav.Position(ordered[i].URL)
+		rankJ, _, inNavJ := nav.Position(ordered[j].URL)
+		if inNavI != inNavJ {
+			return inNavI
+		}
+		return rankI < rankJ
+	})
+
+	f.stableOrder = make(map[string]int64, len(pages))
+	for i, result := range ordered {
+		result.Order = int64(i)
+		result.Title = oneLine(result.Title)
+		result.Content = normalizeWhitespace(result.Content)
+		result.FetchedAt = time.Time{}
+		result.Links = nil
+		for _, known := range []string{result.URL, result.CanonicalURL} {
+			if known != "" {
+				f.stableOrder[canonicalKeyString(known)] = result.Order
+			}
+		}
+		result.fetchedURL, result.URL = result.URL, stableURL(result)
+	}
+	copy(results, ordered)
+}
+
+// stableURL names a page by its canonical URL, which does not depend on
+// which of its URLs was fetched first
+func stableURL(result *PageResult) string {
+	if result.CanonicalURL != "" {
+		return result.CanonicalURL
+	}
+	return result.URL
+}
+
+// normalizeWhitespace unifies line endings, drops trailing whitespace and
+// collapses runs of blank lines outside code blocks
+func normalizeWhitespace(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+	content = trailingSpace.ReplaceAllString(content+"\n", "\n")
+
+	var lines []string
+	fence := ""
+	blank := false
+	for _, line := range strings.Split(strings.Trim(content, "\n"), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		switch {
+		case fence != "":
+			if closesCodeFence(trimmed, fence) {
+				fence = ""
+			}
+		case codeFenceMarker(trimmed) != "":
+			fence = codeFenceMarker(trimmed)
+		case line == "":
+			if blank {
+				continue
+			}
+		}
+		blank = line == "" && fence == ""
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stableOrderOf returns a page's reproducible position, or fallback if it has none
+func (f *OptimizedFetcher) stableOrderOf(pageURL string, fallback int64) int64 {
+	if order, ok := f.stableOrder[canonicalKeyString(pageURL)]; ok {
+		return order
+	}
+	return fallback
+}
@@ -0,0 +1,154 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// retryPolicy decides whether a failed fetch is worth retrying and how long to wait.
+// Retries are capped per page and by a budget shared across the whole run.
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	budget     int32 // Retries left for the run
+	retries    int32 // Retries performed
+	recovered  int32 // Pages that succeeded after at least one retry
+	failed     int32 // Pages that still failed after retrying
+}
+
+// newRetryPolicy creates a policy allowing maxRetries per page and budget retries per run
+func newRetryPolicy(maxRetries, budget int, baseDelay, maxDelay time.Duration) *retryPolicy {
+	return &retryPolicy{
+		maxRetries: maxRetries,
+		baseDelay:  baseDelay,
+		maxDelay:   maxDelay,
+		budget:     int32(budget),
+	}
+}
+
+// retryableStatus reports whether an HTTP status is likely to succeed on a later attempt
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout,
+		http.StatusTooEarly,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// statusError is a non-200 HTTP response
+type statusError struct {
+	Status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("non-200 status %d", e.Status)
+}
+
+// isRetryable classifies a fetch error as transient (worth retrying) or permanent
+func isRetryable(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.Status)
+	}
+	return retryableError(err)
+}
+
+// retryableError reports whether a transport error is transient (timeouts, resets, refused connections)
+func retryableError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// delay returns the wait before retry number attempt (starting at 1): exponential
+// backoff with equal jitter, or the server's Retry-After if that is longer
+func (rp *retryPolicy) delay(attempt int, retryAfter time.Duration) time.Duration {
+	backoff := rp.baseDelay << (attempt - 1)
+	if backoff > rp.maxDelay || backoff <= 0 {
+		backoff = rp.maxDelay
+	}
+
+	// Spread retries out so workers that failed together do not retry together
+	half := backoff / 2
+	wait := half + time.Duration(rand.Int63n(int64(half)+1))
+
+	if retryAfter > wait {
+		wait = retryAfter
+	}
+	return wait
+}
+
+// allow reports whether retry number attempt may go ahead, spending one unit of the run budget
+func (rp *retryPolicy) allow(attempt int, retryAfter time.Duration) bool {
+	if attempt > rp.maxRetries {
+		return false
+	}
+
+	// Waiting longer than maxDelay would stall a worker; give up instead
+	if retryAfter > rp.maxDelay {
+		return false
+	}
+
+	if atomic.AddInt32(&rp.budget, -1) < 0 {
+		return false
+	}
+
+	atomic.AddInt32(&rp.retries, 1)
+	return true
+}
+
+// finish records how a page that needed retries ended up
+func (rp *retryPolicy) finish(attempts int, err error) {
+	if attempts <= 1 {
+		return
+	}
+	if err == nil {
+		atomic.AddInt32(&rp.recovered, 1)
+	} else {
+		atomic.AddInt32(&rp.failed, 1)
+	}
+}
+
+// sleep waits for d or until ctx ends
+func (rp *retryPolicy) sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("retry wait interrupted: %w", ctx.Err())
+	}
+}
+
+// counts reports retries performed, pages recovered by retrying and pages that still failed
+func (rp *retryPolicy) counts() (retries, recovered, failed int) {
+	return int(atomic.LoadInt32(&rp.retries)), int(atomic.LoadInt32(&rp.recovered)), int(atomic.LoadInt32(&rp.failed))
+}
@@ -0,0 +1,108 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"429", &statusError{Status: http.StatusTooManyRequests}, true},
+		{"503", &statusError{Status: http.StatusServiceUnavailable}, true},
+		{"500", &statusError{Status: http.StatusInternalServerError}, true},
+		{"408", &statusError{Status: http.StatusRequestTimeout}, true},
+		{"404", &statusError{Status: http.StatusNotFound}, false},
+		{"403", &statusError{Status: http.StatusForbidden}, false},
+		{"501", &statusError{Status: http.StatusNotImplemented}, false},
+		{"wrapped status", fmt.Errorf("fetch: %w", &statusError{Status: http.StatusBadGateway}), true},
+		{"connection reset", &net.OpError{Op: "read", Err: syscall.ECONNRESET}, true},
+		{"connection refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{"timeout", &net.DNSError{Err: "timeout", IsTimeout: true}, true},
+		{"unexpected EOF", io.ErrUnexpectedEOF, true},
+		{"unknown host", &net.DNSError{Err: "no such host", IsNotFound: true}, false},
+		{"cancelled", context.Canceled, false},
+		{"other", errors.New("malformed response"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyAllow(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+		budget     int
+		attempts   []int         // Retry numbers asked for, in order
+		retryAfter time.Duration // Server's Retry-After for every attempt
+		want       []bool        // Whether each was allowed
+		wantCount  int           // Retries performed
+	}{
+		{name: "within the per-page limit", maxRetries: 2, budget: 10, attempts: []int{1, 2, 3}, want: []bool{true, true, false}, wantCount: 2},
+		{name: "budget runs out across pages", maxRetries: 3, budget: 2, attempts: []int{1, 1, 1, 2}, want: []bool{true, true, false, false}, wantCount: 2},
+		{name: "no budget", maxRetries: 3, budget: 0, attempts: []int{1}, want: []bool{false}},
+		{name: "Retry-After longer than the maximum delay", maxRetries: 3, budget: 10, attempts: []int{1}, retryAfter: time.Hour, want: []bool{false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rp := newRetryPolicy(tt.maxRetries, tt.budget, 100*time.Millisecond, time.Minute)
+			for i, attempt := range tt.attempts {
+				if got := rp.allow(attempt, tt.retryAfter); got != tt.want[i] {
+					t.Errorf("allow(%d) #%d = %v, want %v", attempt, i+1, got, tt.want[i])
+				}
+			}
+			if retries, _, _ := rp.counts(); retries != tt.wantCount {
+				t.Errorf("retries = %d, want %d", retries, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	rp := newRetryPolicy(5, 10, 100*time.Millisecond, time.Second)
+	tests := []struct {
+		attempt    int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{attempt: 1, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{attempt: 3, min: 200 * time.Millisecond, max: 400 * time.Millisecond},
+		{attempt: 10, min: 500 * time.Millisecond, max: time.Second},
+		{attempt: 1, retryAfter: 700 * time.Millisecond, min: 700 * time.Millisecond, max: 700 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if got := rp.delay(tt.attempt, tt.retryAfter); got < tt.min || got > tt.max {
+				t.Fatalf("delay(%d, %v) = %v, want between %v and %v", tt.attempt, tt.retryAfter, got, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestRetryPolicyFinish(t *testing.T) {
+	rp := newRetryPolicy(3, 10, time.Millisecond, time.Second)
+	rp.finish(1, nil)
+	rp.finish(2, nil)
+	rp.finish(3, errors.New("still failing"))
+	if _, recovered, failed := rp.counts(); recovered != 1 || failed != 1 {
+		t.Errorf("recovered %d, failed %d; want 1, 1", recovered, failed)
+	}
+}
@@ -0,0 +1,265 @@
+package fetcher
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRobotsSize is how much of a robots.txt file is parsed (RFC 9309 asks for at least 500 KiB)
+const maxRobotsSize = 512 * 1024
+
+// robotsRules holds the robots.txt directives that apply to our user agent
+type robotsRules struct {
+	rules       []robotsRule
+	disallowAll bool
+	crawlDelay  time.Duration
+	sitemaps    []string
+}
+
+// robotsRule is a single Allow or Disallow line
+type robotsRule struct {
+	pattern string
+	allow   bool
+	re      *regexp.Regexp
+}
+
+// robotsCache fetches robots.txt once per host and answers allow/deny questions
+type robotsCache struct {
+	client    *http.Client
+	wait      func(req *http.Request) error // Holds requests back for the host's rate limit (nil = no limit)
+	userAgent string
+	mu        sync.Mutex
+	hosts     map[string]*robotsEntry
+}
+
+// robotsEntry lets concurrent workers share a single robots.txt fetch per host
+type robotsEntry struct {
+	once  sync.Once
+	rules *robotsRules
+}
+
+// newRobotsCache creates a robots.txt cache that fetches with the given
+// client, waiting for the rate limiter first
+func newRobotsCache(client *http.Client, wait func(req *http.Request) error, userAgent string) *robotsCache {
+	return &robotsCache{
+		client:    client,
+		wait:      wait,
+		userAgent: userAgent,
+		hosts:     make(map[string]*robotsEntry),
+	}
+}
+
+// get returns the rules for the URL's host, fetching robots.txt on first use
+func (rc *robotsCache) get(ctx context.Context, pageURL *url.URL) *robotsRules {
+	key := pageURL.Scheme + "://" + pageURL.Host
+
+	rc.mu.Lock()
+	entry, exists := rc.hosts[key]
+	if !exists {
+		entry = &robotsEntry{}
+		rc.hosts[key] = entry
+	}
+	rc.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.rules = rc.fetch(ctx, key+"/robots.txt")
+	})
+	return entry.rules
+}
+
+// allowed reports whether robots.txt permits fetching the URL
+func (rc *robotsCache) allowed(ctx context.Context, pageURL string) bool {
+	parsed, err := url.Parse(pageURL)
+	if err != nil {
+		return false
+	}
+	return rc.get(ctx, parsed).allowed(parsed)
+}
+
+// fetch downloads and parses a robots.txt file following RFC 9309:
+// a missing file allows everything, an unreachable one disallows everything
+func (rc *robotsCache) fetch(ctx context.Context, robotsURL string) *robotsRules {
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+	if err != nil {
+		return &robotsRules{}
+	}
+	req.Header.Set("User-Agent", rc.userAgent)
+
+	if rc.wait != nil {
+		if err := rc.wait(req); err != nil {
+			// Only a stopping crawl ends the wait early, and it fetches nothing more
+			return &robotsRules{disallowAll: true}
+		}
+	}
+
+	resp, err := rc.client.Do(req)
+	if errors.Is(err, errNotCached) {
+		// Offline and never fetched: the cached pages were allowed when they were fetched
+		return &robotsRules{}
+	}
+	if err != nil {
+		log.Printf("⚠️  Could not fetch %s (%v); treating host as disallowed (use --ignore-robots to override)", robotsURL, err)
+		return &robotsRules{disallowAll: true}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		log.Printf("⚠️  %s returned %d; treating host as disallowed (use --ignore-robots to override)", robotsURL, resp.StatusCode)
+		return &robotsRules{disallowAll: true}
+	}
+	if resp.StatusCode != 200 {
+		// No robots.txt (4xx) means no restrictions
+		return &robotsRules{}
+	}
+
+	rules := parseRobots(io.LimitReader(resp.Body, maxRobotsSize), rc.userAgent)
+	if rules.crawlDelay > 0 {
+		log.Printf("🤖 %s asks for a %v crawl delay", robotsURL, rules.crawlDelay)
+	}
+	return rules
+}
+
+// parseRobots extracts the group naming userAgent's product token exactly,
+// ignoring case (or the * group), from a robots.txt body
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	token := robotsProductToken(userAgent)
+
+	var specific, wildcard robotsRules
+	var sitemaps []string
+	matchedSpecific := false
+
+	// Groups start with one or more User-agent lines and run until the next User-agent after a rule
+	var agents []string
+	inRules := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxRobotsSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				agents = nil
+				inRules = false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "allow", "disallow", "crawl-delay":
+			inRules = true
+			for _, agent := range agents {
+				var target *robotsRules
+				switch {
+				case agent == "*":
+					target = &wildcard
+				case agent != "" && agent == token:
+					target = &specific
+					matchedSpecific = true
+				default:
+					continue
+				}
+				addRobotsDirective(target, key, value)
+			}
+		case "sitemap":
+			// Sitemap lines apply to the whole file, not a group
+			sitemaps = append(sitemaps, value)
+		}
+	}
+
+	rules := &wildcard
+	if matchedSpecific {
+		rules = &specific
+	}
+	rules.sitemaps = sitemaps
+	return rules
+}
+
+// addRobotsDirective adds one Allow, Disallow or Crawl-delay line to a group
+func addRobotsDirective(rules *robotsRules, key, value string) {
+	switch key {
+	case "crawl-delay":
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+			rules.crawlDelay = time.Duration(seconds * float64(time.Second))
+		}
+	case "allow", "disallow":
+		// An empty Disallow means "allow everything" and adds nothing
+		if value == "" {
+			return
+		}
+		rules.rules = append(rules.rules, robotsRule{
+			pattern: value,
+			allow:   key == "allow",
+			re:      compileRobotsPattern(value),
+		})
+	}
+}
+
+// compileRobotsPattern turns a robots.txt path pattern with * and $ into an anchored regexp
+func compileRobotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// robotsProductToken returns the lowercased product name from a user agent, e.g. "docfetch" from "DocFetch/1.0"
+func robotsProductToken(userAgent string) string {
+	token := strings.ToLower(strings.TrimSpace(userAgent))
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+	return token
+}
+
+// allowed applies the longest matching rule to the URL path; Allow wins ties
+func (r *robotsRules) allowed(pageURL *url.URL) bool {
+	path := pageURL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	if r.disallowAll {
+		return false
+	}
+	if pageURL.RawQuery != "" {
+		path += "?" + pageURL.RawQuery
+	}
+
+	allowed := true
+	longest := -1
+	for _, rule := range r.rules {
+		if !rule.re.MatchString(path) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			longest = len(rule.pattern)
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,147 @@
+package fetcher
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRobotsGroups(t *testing.T) {
+	const body = `
+User-agent: *
+Disallow: /private/
+
+User-agent: bot
+Disallow: /
+
+User-agent: OtherBot
+User-agent: MyBot
+Disallow: /drafts/
+Allow: /drafts/public
+Crawl-delay: 2
+`
+
+	tests := []struct {
+		name      string
+		userAgent string
+		allowed   []string
+		blocked   []string
+		delay     time.Duration
+	}{
+		{
+			name:      "exact product token",
+			userAgent: "MyBot/1.0",
+			allowed:   []string{"/private/page", "/drafts/public/page"},
+			blocked:   []string{"/drafts/page"},
+			delay:     2 * time.Second,
+		},
+		{
+			name:      "token is matched ignoring case",
+			userAgent: "mybot",
+			allowed:   []string{"/private/page"},
+			blocked:   []string{"/drafts/page"},
+			delay:     2 * time.Second,
+		},
+		{
+			name:      "any agent of a multi-agent group",
+			userAgent: "OtherBot/2.0 (+https://example.com)",
+			allowed:   []string{"/private/page"},
+			blocked:   []string{"/drafts/page"},
+			delay:     2 * time.Second,
+		},
+		{
+			name:      "substring of the token falls back to the wildcard group",
+			userAgent: "Robot/1.0",
+			allowed:   []string{"/", "/drafts/page"},
+			blocked:   []string{"/private/page"},
+		},
+		{
+			name:      "token containing a group name falls back to the wildcard group",
+			userAgent: "MyBotPlus/1.0",
+			allowed:   []string{"/", "/drafts/page"},
+			blocked:   []string{"/private/page"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := parseRobots(strings.NewReader(body), tt.userAgent)
+			check := func(paths []string, want bool) {
+				for _, path := range paths {
+					if got := rules.allowed(&url.URL{Scheme: "https", Host: "example.com", Path: path}); got != want {
+						t.Errorf("allowed(%s) = %v, want %v", path, got, want)
+					}
+				}
+			}
+			check(tt.allowed, true)
+			check(tt.blocked, false)
+			if rules.crawlDelay != tt.delay {
+				t.Errorf("crawlDelay = %v, want %v", rules.crawlDelay, tt.delay)
+			}
+		})
+	}
+}
+
+func TestParseRobotsEmptyUserAgent(t *testing.T) {
+	const body = `
+User-agent:
+Disallow: /
+
+User-agent: *
+Disallow: /private/
+`
+	rules := parseRobots(strings.NewReader(body), "")
+	if !rules.allowed(&url.URL{Scheme: "https", Host: "example.com", Path: "/docs"}) {
+		t.Error("an empty User-agent line matched an empty user agent")
+	}
+}
+
+// roundTripFunc answers requests without a network
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestRobotsCacheWaitsForRateLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		waitErr     error
+		wantFetches int
+		wantAllowed bool
+	}{
+		{name: "fetched after the wait", wantFetches: 1, wantAllowed: true},
+		{name: "interrupted wait fetches nothing", waitErr: context.Canceled, wantAllowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetches := 0
+			client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				fetches++
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("User-agent: *\nDisallow: /private/\n")), Request: req}, nil
+			})}
+
+			var waited []string
+			wait := func(req *http.Request) error {
+				waited = append(waited, req.URL.String())
+				return tt.waitErr
+			}
+
+			rc := newRobotsCache(client, wait, "MyBot/1.0")
+			for i := 0; i < 3; i++ {
+				if got := rc.allowed(context.Background(), "https://docs.example.com/guide"); got != tt.wantAllowed {
+					t.Errorf("allowed() = %v, want %v", got, tt.wantAllowed)
+				}
+			}
+			if len(waited) != 1 || waited[0] != "https://docs.example.com/robots.txt" {
+				t.Errorf("waited for %v, want one wait for robots.txt", waited)
+			}
+			if fetches != tt.wantFetches {
+				t.Errorf("fetched robots.txt %d times, want %d", fetches, tt.wantFetches)
+			}
+		})
+	}
+}
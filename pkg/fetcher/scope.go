@@ -0,0 +1,161 @@
+package fetcher
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// crawlScope decides which discovered URLs belong to the crawl
+type crawlScope struct {
+	host     string
+	prefix   string // Path prefix every URL must live under ("" = whole host)
+	includes []scopePattern
+	excludes []scopePattern
+}
+
+// scopePattern is a user-supplied include or exclude pattern.
+// Patterns prefixed with "re:" are regular expressions matched against the full URL;
+// anything else is a glob matched against the path, or the full URL if it contains "://".
+type scopePattern struct {
+	raw     string
+	re      *regexp.Regexp
+	fullURL bool
+}
+
+// newCrawlScope builds the scope for a crawl of baseURL
+func newCrawlScope(baseURL *url.URL, config Config) (*crawlScope, error) {
+	// Discovered URLs are cleaned before they are checked, so the host must be too
+	scope := &crawlScope{host: cleanURL(baseURL).Host}
+
+	if !config.NoPrefixLock {
+		scope.prefix = config.ScopePrefix
+		if scope.prefix == "" {
+			scope.prefix = defaultScopePrefix(baseURL.Path)
+		}
+		scope.prefix = strings.TrimSuffix(scope.prefix, "/")
+	}
+
+	var err error
+	if scope.includes, err = compileScopePatterns(config.IncludePatterns); err != nil {
+		return nil, err
+	}
+	if scope.excludes, err = compileScopePatterns(config.ExcludePatterns); err != nil {
+		return nil, err
+	}
+	return scope, nil
+}
+
+// defaultScopePrefix locks the crawl to the base URL's directory, e.g.
+// /docs for /docs/ or /docs/intro.html, and /learn for /learn
+func defaultScopePrefix(basePath string) string {
+	if basePath == "" || basePath == "/" {
+		return ""
+	}
+	if strings.HasSuffix(basePath, "/") {
+		return strings.TrimSuffix(basePath, "/")
+	}
+
+	// A last segment with an extension is a page, so lock to its directory
+	if path.Ext(path.Base(basePath)) != "" {
+		dir := path.Dir(basePath)
+		if dir == "/" {
+			return ""
+		}
+		return dir
+	}
+	return basePath
+}
+
+// compileScopePatterns compiles include or exclude patterns
+func compileScopePatterns(patterns []string) ([]scopePattern, error) {
+	var compiled []scopePattern
+	for _, raw := range patterns {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+
+		pattern := scopePattern{raw: raw, fullURL: true}
+		var err error
+		if expr, ok := strings.CutPrefix(raw, "re:"); ok {
+			pattern.re, err = regexp.Compile(expr)
+		} else {
+			pattern.fullURL = strings.Contains(raw, "://")
+			pattern.re, err = regexp.Compile(globToRegexp(raw))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid scope pattern %q: %w", raw, err)
+		}
+
+		compiled = append(compiled, pattern)
+	}
+	return compiled, nil
+}
+
+// globToRegexp converts a glob where * matches within a path segment,
+// ** matches across segments and ? matches one character
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+// matches reports whether the pattern matches the URL
+func (p scopePattern) matches(u *url.URL) bool {
+	if p.fullURL {
+		return p.re.MatchString(u.String())
+	}
+	return p.re.MatchString(u.Path)
+}
+
+// check returns why a URL is outside the scope, or "" if it is in scope
+func (s *crawlScope) check(u *url.URL) string {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Sprintf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Host != s.host {
+		return fmt.Sprintf("different host %s", u.Host)
+	}
+	if isNonHTMLResource(u.Path) {
+		return "non-HTML resource"
+	}
+	if s.prefix != "" && u.Path != s.prefix && !strings.HasPrefix(u.Path, s.prefix+"/") {
+		return fmt.Sprintf("outside path prefix %s/", s.prefix)
+	}
+
+	for _, pattern := range s.excludes {
+		if pattern.matches(u) {
+			return fmt.Sprintf("matches exclude pattern %q", pattern.raw)
+		}
+	}
+
+	if len(s.includes) > 0 {
+		for _, pattern := range s.includes {
+			if pattern.matches(u) {
+				return ""
+			}
+		}
+		return "matches no include pattern"
+	}
+
+	return ""
+}
@@ -0,0 +1,68 @@
+package fetcher
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCrawlScopeHost(t *testing.T) {
+	tests := []struct {
+		name string
+		base string
+		link string
+		want string // Reason the link is out of scope ("" = in scope)
+	}{
+		{
+			name: "mixed-case base host",
+			base: "http://Docs.Test/docs/",
+			link: "http://docs.test/docs/intro",
+		},
+		{
+			name: "mixed-case link host",
+			base: "http://docs.test/docs/",
+			link: "HTTP://DOCS.TEST/docs/intro",
+		},
+		{
+			name: "explicit default http port",
+			base: "http://docs.test:80/docs/",
+			link: "http://docs.test/docs/intro",
+		},
+		{
+			name: "explicit default https port",
+			base: "https://docs.test:443/docs/",
+			link: "https://docs.test:443/docs/intro",
+		},
+		{
+			name: "other port is another host",
+			base: "http://docs.test:8080/docs/",
+			link: "http://docs.test/docs/intro",
+			want: "different host docs.test",
+		},
+		{
+			name: "other host",
+			base: "http://docs.test/docs/",
+			link: "http://blog.docs.test/docs/intro",
+			want: "different host blog.docs.test",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, err := url.Parse(tt.base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scope, err := newCrawlScope(base, Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			link, err := url.Parse(tt.link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := scope.check(cleanURL(link)); got != tt.want {
+				t.Errorf("check(%s) = %q, want %q", tt.link, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// Extraction strategies, in the order extractContent tries them. A SiteRule
+// can restrict a site to some of them.
+const (
+	StrategyProfile     = "profile"     // Known layout of the detected documentation generator
+	StrategySemantic    = "semantic"    // <main>, <article> and ARIA roles
+	StrategyClass       = "class"       // Common content class and id names
+	StrategyReadability = "readability" // Best-scoring article candidate, as in Mozilla Readability
+	StrategyBody        = "body"        // The whole cleaned-up <body>
+)
+
+// extractionStrategies lists every strategy a SiteRule may enable
+var extractionStrategies = []string{StrategyProfile, StrategySemantic, StrategyClass, StrategyReadability, StrategyBody}
+
+// SiteConfig is the file format for per-site extraction overrides
+type SiteConfig struct {
+	Sites []SiteRule `json:"sites"`
+}
+
+// SiteRule overrides content extraction for the pages it matches
+type SiteRule struct {
+	// Match is a host ("docs.example.com"), a glob ("/api/**" against the path,
+	// "https://example.com/docs/*" against the full URL) or a "re:" regular expression
+	Match      string   `json:"match"`
+	Content    []string `json:"content,omitempty"`    // Content selectors, tried before any strategy
+	Remove     []string `json:"remove,omitempty"`     // Selectors removed before extracting
+	Title      string   `json:"title,omitempty"`      // Selector for the page title (default: <title>)
+	Strategies []string `json:"strategies,omitempty"` // Fallback strategies allowed if Content finds nothing (empty = all)
+
+	pattern *scopePattern // Compiled Match; nil for a host match
+}
+
+// LoadSiteConfig reads site rules from a JSON file
+func LoadSiteConfig(path string) (*SiteConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read site config: %w", err)
+	}
+
+	var siteConfig SiteConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&siteConfig); err != nil {
+		return nil, fmt.Errorf("invalid site config %s: %w", path, err)
+	}
+
+	if _, err := compileSiteRules(siteConfig.Sites); err != nil {
+		return nil, fmt.Errorf("invalid site config %s: %w", path, err)
+	}
+	return &siteConfig, nil
+}
+
+// compileSiteRules validates rules and returns copies with their patterns compiled
+func compileSiteRules(rules []SiteRule) ([]SiteRule, error) {
+	compiled := make([]SiteRule, 0, len(rules))
+	for i, rule := range rules {
+		rule.Match = strings.TrimSpace(rule.Match)
+		if rule.Match == "" {
+			return nil, fmt.Errorf("site rule %d has no match", i+1)
+		}
+
+		if !isHostMatch(rule.Match) {
+			patterns, err := compileScopePatterns([]string{rule.Match})
+			if err != nil {
+				return nil, err
+			}
+			rule.pattern = &patterns[0]
+		}
+
+		for _, strategy := range rule.Strategies {
+			if !isExtractionStrategy(strategy) {
+				return nil, fmt.Errorf("site rule %q: unknown strategy %q (use %s)", rule.Match, strategy, strings.Join(extractionStrategies, ", "))
+			}
+		}
+
+		for _, selector := range append(append(append([]string{}, rule.Content...), rule.Remove...), rule.Title) {
+			if selector == "" {
+				continue
+			}
+			if err := checkSelector(selector); err != nil {
+				return nil, fmt.Errorf("site rule %q: invalid selector %q: %v", rule.Match, selector, err)
+			}
+		}
+
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+// isHostMatch reports whether a rule's match is a bare host name rather than a pattern
+func isHostMatch(match string) bool {
+	return !strings.ContainsAny(match, "/*?") && !strings.HasPrefix(match, "re:")
+}
+
+// isExtractionStrategy reports whether name is a known strategy
+func isExtractionStrategy(name string) bool {
+	for _, strategy := range extractionStrategies {
+		if name == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSelector reports whether a CSS selector compiles; goquery silently matches nothing otherwise
+func checkSelector(selector string) error {
+	_, err := cascadia.Compile(selector)
+	return err
+}
+
+// matches reports whether the rule applies to a page URL
+func (r *SiteRule) matches(u *url.URL) bool {
+	if u == nil {
+		return false
+	}
+	if r.pattern == nil {
+		return strings.EqualFold(u.Hostname(), r.Match) || strings.EqualFold(u.Host, r.Match)
+	}
+	return r.pattern.matches(u)
+}
+
+// allows reports whether the rule lets extraction fall back to a strategy
+func (r *SiteRule) allows(strategy string) bool {
+	if r == nil || len(r.Strategies) == 0 {
+		return true
+	}
+	for _, allowed := range r.Strategies {
+		if allowed == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+// findSiteRule returns the first rule matching a page, or nil
+func findSiteRule(rules []SiteRule, u *url.URL) *SiteRule {
+	for i := range rules {
+		if rules[i].matches(u) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// extractWithSiteRule tries the rule's content selectors in order
+func extractWithSiteRule(doc *goquery.Document, rule *SiteRule, converter *markdownConverter) (content, selector string) {
+	for _, selector := range rule.Content {
+		el := doc.Find(selector).First()
+		if el.Length() == 0 {
+			continue
+		}
+
+		clone := el.Clone()
+		clone.Find(profileNoise).Remove()
+		if content := converter.convert(clone.Get(0)); content != "" {
+			return content, selector
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,210 @@
+package fetcher
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// maxSitemapSize is the largest sitemap the protocol allows (50 MB uncompressed)
+	maxSitemapSize = 50 * 1024 * 1024
+	// maxSitemapNesting bounds how deep sitemap indexes may point to other indexes
+	maxSitemapNesting = 3
+	// maxSitemapFiles bounds how many sitemap files a single crawl will read
+	maxSitemapFiles = 1000
+)
+
+// sitemapDocument is either a <urlset> of pages or a <sitemapindex> of other sitemaps
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapEntry `xml:"url"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+// sitemapEntry is a single <url> or <sitemap> entry
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// sitemapLastModLayouts are the W3C datetime forms allowed in <lastmod>
+var sitemapLastModLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// sitemapSources lists the sitemaps to seed from: an explicit --sitemap-url,
+// then any advertised in robots.txt, falling back to /sitemap.xml in sitemap mode
+func (f *OptimizedFetcher) sitemapSources() []string {
+	base := f.baseURL
+
+	var sources []string
+	if f.config.SitemapURL != "" {
+		sources = append(sources, f.config.SitemapURL)
+	}
+	if f.robots != nil {
+		sources = append(sources, f.robots.get(f.ctx, base).sitemaps...)
+	}
+	if f.config.UseSitemap && len(sources) == 0 {
+		sources = append(sources, base.Scheme+"://"+base.Host+"/sitemap.xml")
+	}
+
+	seen := make(map[string]bool)
+	unique := sources[:0]
+	for _, source := range sources {
+		if !seen[source] {
+			seen[source] = true
+			unique = append(unique, source)
+		}
+	}
+	return unique
+}
+
+// seedFromSitemaps queues every in-scope page listed in the given sitemaps,
+// newest first when SitemapLastModOrder is set
+func (f *OptimizedFetcher) seedFromSitemaps(sources []string) {
+	seen := make(map[string]bool)
+	var entries []sitemapEntry
+
+	for _, source := range sources {
+		found, err := f.collectSitemap(source, 0, seen)
+		if err != nil {
+			log.Printf("⚠️  Skipping sitemap %s: %v", source, err)
+		}
+		entries = append(entries, found...)
+	}
+
+	if f.config.SitemapLastModOrder {
+		sortSitemapEntriesByLastMod(entries)
+	}
+
+	seeded := 0
+	for _, entry := range entries {
+		if f.submitPage(entry.Loc, 1, f.config.BaseURL) {
+			seeded++
+		}
+	}
+	log.Printf("🗺️  Seeded %d of %d sitemap URLs from %d sitemap(s)", seeded, len(entries), len(seen))
+}
+
+// collectSitemap reads a sitemap, following sitemap indexes into nested sitemaps
+func (f *OptimizedFetcher) collectSitemap(sitemapURL string, level int, seen map[string]bool) ([]sitemapEntry, error) {
+	if seen[sitemapURL] || len(seen) >= maxSitemapFiles {
+		return nil, nil
+	}
+	seen[sitemapURL] = true
+
+	doc, err := f.fetchSitemap(sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []sitemapEntry
+	for _, entry := range doc.URLs {
+		entry.Loc = strings.TrimSpace(entry.Loc)
+		if entry.Loc != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	if len(doc.Sitemaps) > 0 && level >= maxSitemapNesting {
+		return entries, fmt.Errorf("sitemap indexes nested deeper than %d levels", maxSitemapNesting)
+	}
+
+	for _, child := range doc.Sitemaps {
+		childURL := strings.TrimSpace(child.Loc)
+		if childURL == "" {
+			continue
+		}
+		found, err := f.collectSitemap(childURL, level+1, seen)
+		if err != nil {
+			log.Printf("⚠️  Skipping sitemap %s: %v", childURL, err)
+		}
+		entries = append(entries, found...)
+	}
+
+	return entries, nil
+}
+
+// fetchSitemap downloads and decodes a single sitemap file, gunzipping it if needed
+func (f *OptimizedFetcher) fetchSitemap(sitemapURL string) (*sitemapDocument, error) {
+	if err := isValidURL(sitemapURL); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(f.ctx, "GET", sitemapURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.config.UserAgent)
+
+	// A sitemap index can list many sitemaps on one host; they share its rate limit
+	if f.robots != nil && !f.cache.has(req) {
+		f.limiter.setCrawlDelay(req.URL.Host, f.robots.get(f.ctx, req.URL).crawlDelay)
+	}
+	if err := f.wait(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	// .xml.gz files arrive still compressed; detect them by their magic bytes
+	body := bufio.NewReader(resp.Body)
+	var reader io.Reader = body
+	if magic, err := body.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip sitemap: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	doc := &sitemapDocument{}
+	if err := xml.NewDecoder(io.LimitReader(reader, maxSitemapSize)).Decode(doc); err != nil {
+		return nil, fmt.Errorf("invalid sitemap XML: %w", err)
+	}
+	return doc, nil
+}
+
+// sortSitemapEntriesByLastMod orders entries newest first; entries without a date go last
+func sortSitemapEntriesByLastMod(entries []sitemapEntry) {
+	modified := make(map[string]time.Time, len(entries))
+	for _, entry := range entries {
+		modified[entry.Loc] = parseSitemapLastMod(entry.LastMod)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return modified[entries[i].Loc].After(modified[entries[j].Loc])
+	})
+}
+
+// parseSitemapLastMod parses a <lastmod> value, returning the zero time if it is missing or invalid
+func parseSitemapLastMod(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range sitemapLastModLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
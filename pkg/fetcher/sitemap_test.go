@@ -0,0 +1,36 @@
+package fetcher
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFetchSitemapWaitsForRateLimit(t *testing.T) {
+	// A cancelled context makes any wait fail at once, so only the burst gets through
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	fetches := 0
+	f := &OptimizedFetcher{
+		ctx:     ctx,
+		limiter: newRateLimiter(0.001, 1, false),
+		httpClient: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			fetches++
+			body := `<urlset><url><loc>https://docs.example.com/guide</loc></url></urlset>`
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+		})},
+	}
+
+	if _, err := f.fetchSitemap("https://docs.example.com/sitemap-1.xml"); err != nil {
+		t.Fatalf("first sitemap: %v", err)
+	}
+	if _, err := f.fetchSitemap("https://docs.example.com/sitemap-2.xml"); err == nil {
+		t.Error("second sitemap skipped the rate limiter")
+	}
+	if fetches != 1 {
+		t.Errorf("fetched %d sitemaps, want 1", fetches)
+	}
+}
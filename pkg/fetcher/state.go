@@ -0,0 +1,253 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// stateVersion is bumped whenever the state file changes incompatibly
+const stateVersion = 1
+
+// crawlState is what an incremental crawl remembers between runs: the
+// validators and extracted Markdown of every page, so the next run can ask
+// the server whether a page changed and reuse the Markdown when it did not
+type crawlState struct {
+	Version int                   `json:"version"`
+	BaseURL string                `json:"base_url"`
+	Nav     *navState             `json:"nav,omitempty"` // Sidebar the crawl used and the page it came from
+	Pages   map[string]*pageState `json:"pages"`         // By canonical key of the requested URL
+}
+
+// pageState is one page of the state file
+type pageState struct {
+	URL          string   `json:"url"`    // Final URL after redirects
+	Status       int      `json:"status"` // HTTP status of the response it was extracted from
+	ETag         string   `json:"etag,omitempty"`
+	LastModified string   `json:"last_modified,omitempty"`
+	BodyHash     string   `json:"body_hash"`    // "sha256:" and the hex digest of the response body
+	ContentHash  string   `json:"content_hash"` // "sha256:" and the hex digest of Content
+	CanonicalURL string   `json:"canonical_url"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description,omitempty"` // Meta description, or the start of the content
+	SiteName     string   `json:"site_name,omitempty"`   // og:site_name
+	Profile      string   `json:"profile,omitempty"`
+	Strategy     string   `json:"strategy"`
+	Confidence   float64  `json:"confidence"`
+	Content      string   `json:"content"`         // Markdown body
+	Links        []string `json:"links,omitempty"` // Absolute URLs of the page's links, sidebar first
+}
+
+// navState is a sidebar as stored in the state file
+type navState struct {
+	Page  string         `json:"page"` // URL of the page it was read from
+	Roots []navNodeState `json:"roots"`
+}
+
+// navNodeState is a NavNode as stored in the state file
+type navNodeState struct {
+	Title    string         `json:"title"`
+	URL      string         `json:"url,omitempty"`
+	Caption  bool           `json:"caption,omitempty"`
+	Children []navNodeState `json:"children,omitempty"`
+}
+
+// stateTracker holds the previous run's state and builds the next one
+type stateTracker struct {
+	prev      *crawlState
+	next      map[string]*pageState
+	mutex     sync.Mutex
+	unchanged int // Pages reused without re-extracting
+	changed   int // Pages whose Markdown is new or differs from the previous run
+}
+
+// loadState reads a state file; a missing file is the empty state of a first run
+func loadState(path string) (*crawlState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &crawlState{Version: stateVersion, Pages: make(map[string]*pageState)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state crawlState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	if state.Version != stateVersion {
+		return nil, fmt.Errorf("state file %s has version %d, expected %d (delete it to start over)", path, state.Version, stateVersion)
+	}
+	if state.Pages == nil {
+		state.Pages = make(map[string]*pageState)
+	}
+	return &state, nil
+}
+
+// newStateTracker starts a run from the previous state
+func newStateTracker(prev *crawlState) *stateTracker {
+	return &stateTracker{prev: prev, next: make(map[string]*pageState)}
+}
+
+// page returns what the previous run stored for a URL, or nil
+func (s *stateTracker) page(pageURL string) *pageState {
+	if s == nil {
+		return nil
+	}
+	return s.prev.Pages[canonicalKeyString(pageURL)]
+}
+
+// record stores a page for the next run and counts whether it changed
+func (s *stateTracker) record(pageURL string, page *pageState, reused bool) {
+	if s == nil {
+		return
+	}
+	key := canonicalKeyString(pageURL)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.next[key] = page
+	if reused {
+		s.unchanged++
+	} else if prev := s.prev.Pages[key]; prev == nil || prev.ContentHash != page.ContentHash {
+		s.changed++
+	}
+}
+
+// counts returns the pages reused, the pages whose Markdown changed and the
+// pages of the previous run this one did not produce. A crawl that stopped
+// early may just not have reached a page, so only a complete one removes any.
+func (s *stateTracker) counts(reason StopReason) (unchanged, changed, removed int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if reason == StopExhausted {
+		for key := range s.prev.Pages {
+			if s.next[key] == nil {
+				removed++
+			}
+		}
+	}
+	return s.unchanged, s.changed, removed
+}
+
+// save writes the pages of this run. Pages of the previous run it did not
+// produce are dropped after a complete crawl and kept after an early stop.
+func (s *stateTracker) save(path, baseURL string, nav *NavTree, navPage string, reason StopReason) error {
+	s.mutex.Lock()
+	state := crawlState{Version: stateVersion, BaseURL: baseURL, Pages: s.next}
+	if nav != nil {
+		state.Nav = &navState{Page: navPage, Roots: navNodeStates(nav.Roots)}
+	}
+	if reason != StopExhausted {
+		state.Pages = make(map[string]*pageState, len(s.prev.Pages)+len(s.next))
+		for key, page := range s.prev.Pages {
+			state.Pages[key] = page
+		}
+		for key, page := range s.next {
+			state.Pages[key] = page
+		}
+		if state.Nav == nil {
+			state.Nav = s.prev.Nav
+		}
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	s.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// writeFileAtomic writes a file next to its destination and swaps it in, so
+// an interrupted write never leaves a half-written file behind
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// unchanged reports whether a response shows the page is the one stored:
+// the server answered 304 Not Modified, or sent the same body again
+func (p *pageState) unchanged(resp *fetchedResponse) bool {
+	if p == nil {
+		return false
+	}
+	return resp.Status == http.StatusNotModified || contentHash(string(resp.Body)) == p.BodyHash
+}
+
+// setConditionalHeaders asks the server to answer 304 if the page has not changed
+func (p *pageState) setConditionalHeaders(req *http.Request) {
+	if p == nil {
+		return
+	}
+	if p.ETag != "" {
+		req.Header.Set("If-None-Match", p.ETag)
+	}
+	if p.LastModified != "" {
+		req.Header.Set("If-Modified-Since", p.LastModified)
+	}
+}
+
+// revalidated is the stored page with the validators of a new response;
+// a 304 may leave them out, which keeps the old ones
+func (p *pageState) revalidated(resp *fetchedResponse) *pageState {
+	page := *p
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		page.ETag = etag
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		page.LastModified = lastModified
+	}
+	return &page
+}
+
+// navNodeStates converts a sidebar for the state file
+func navNodeStates(nodes []*NavNode) []navNodeState {
+	var states []navNodeState
+	for _, node := range nodes {
+		states = append(states, navNodeState{
+			Title:    node.Title,
+			URL:      node.URL,
+			Caption:  node.caption,
+			Children: navNodeStates(node.Children),
+		})
+	}
+	return states
+}
+
+// tree rebuilds the stored sidebar
+func (n *navState) tree() *NavTree {
+	var nodes func(states []navNodeState) []*NavNode
+	nodes = func(states []navNodeState) []*NavNode {
+		var built []*NavNode
+		for _, state := range states {
+			built = append(built, &NavNode{Title: state.Title, URL: state.URL, caption: state.Caption, Children: nodes(state.Children)})
+		}
+		return built
+	}
+
+	tree := &NavTree{Roots: nodes(n.Roots)}
+	tree.index()
+	return tree
+}
@@ -0,0 +1,55 @@
+package fetcher
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestStateTrackerSave(t *testing.T) {
+	prev := &crawlState{Version: stateVersion, Pages: map[string]*pageState{
+		canonicalKeyString("https://docs.example.com/a"): {URL: "https://docs.example.com/a", ContentHash: "sha256:a"},
+		canonicalKeyString("https://docs.example.com/b"): {URL: "https://docs.example.com/b", ContentHash: "sha256:b"},
+	}}
+
+	tests := []struct {
+		name        string
+		reason      StopReason
+		wantPages   []string
+		wantRemoved int
+	}{
+		{name: "complete crawl drops pages it did not reach", reason: StopExhausted, wantPages: []string{"https://docs.example.com/a"}, wantRemoved: 1},
+		{name: "budget stop keeps them", reason: StopBudget, wantPages: []string{"https://docs.example.com/a", "https://docs.example.com/b"}},
+		{name: "timeout keeps them", reason: StopTimeout, wantPages: []string{"https://docs.example.com/a", "https://docs.example.com/b"}},
+		{name: "cancelled crawl keeps them", reason: StopCancelled, wantPages: []string{"https://docs.example.com/a", "https://docs.example.com/b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStateTracker(prev)
+			s.record("https://docs.example.com/a", &pageState{URL: "https://docs.example.com/a", ContentHash: "sha256:a"}, true)
+
+			if unchanged, changed, removed := s.counts(tt.reason); unchanged != 1 || changed != 0 || removed != tt.wantRemoved {
+				t.Errorf("counts() = %d, %d, %d; want 1, 0, %d", unchanged, changed, removed, tt.wantRemoved)
+			}
+
+			path := filepath.Join(t.TempDir(), "state.json")
+			if err := s.save(path, "https://docs.example.com/", nil, "", tt.reason); err != nil {
+				t.Fatal(err)
+			}
+			saved, err := loadState(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var pages []string
+			for _, page := range saved.Pages {
+				pages = append(pages, page.URL)
+			}
+			sort.Strings(pages)
+			if !reflect.DeepEqual(pages, tt.wantPages) {
+				t.Errorf("saved pages = %v, want %v", pages, tt.wantPages)
+			}
+		})
+	}
+}
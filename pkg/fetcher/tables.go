@@ -0,0 +1,461 @@
+package fetcher
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Table policies decide how tables that cannot be a GFM pipe table are rendered,
+// i.e. tables with colspan/rowspan or with code blocks or nested tables in cells
+const (
+	TablePolicyHTML = "html" // Keep a cleaned-up HTML table (default)
+	TablePolicyList = "list" // Flatten each row into a list item of "column: value" pairs
+)
+
+// tableCell is one slot of a table grid. A cell spanning several rows or
+// columns fills several slots with the same *tableCell.
+type tableCell struct {
+	node    *html.Node
+	header  bool
+	colspan int
+	rowspan int
+}
+
+// table renders a <table> as a GFM pipe table, falling back to the table policy for complex tables
+func (mc *markdownConverter) table(n *html.Node) string {
+	rows := tableRows(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	if isComplexTable(rows) {
+		if mc.tablePolicy == TablePolicyList {
+			return mc.tableAsList(rows)
+		}
+		return mc.tableAsHTML(rows)
+	}
+	return mc.pipeTable(rows)
+}
+
+// pipeTable renders a simple table as a GFM pipe table. The first row is the header.
+func (mc *markdownConverter) pipeTable(rows []*html.Node) string {
+	var grid [][]string
+	var aligns []string
+	columns := 0
+	for i, tr := range rows {
+		var row []string
+		for _, cell := range rowCells(tr) {
+			row = append(row, escapeTableCell(mc.cellMarkdown(cell)))
+			if i == 0 {
+				aligns = append(aligns, cellAlignment(cell))
+			}
+		}
+		if len(row) == 0 {
+			continue
+		}
+		if len(row) > columns {
+			columns = len(row)
+		}
+		grid = append(grid, row)
+	}
+	if len(grid) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	for i, row := range grid {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		sb.WriteString("| " + strings.Join(row, " | ") + " |\n")
+
+		if i == 0 {
+			for c := 0; c < columns; c++ {
+				align := ""
+				if c < len(aligns) {
+					align = aligns[c]
+				}
+				switch align {
+				case "left":
+					sb.WriteString("| :--- ")
+				case "center":
+					sb.WriteString("| :---: ")
+				case "right":
+					sb.WriteString("| ---: ")
+				default:
+					sb.WriteString("| --- ")
+				}
+			}
+			sb.WriteString("|\n")
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// cellMarkdown renders a cell's content on one line, joining its blocks with <br>
+func (mc *markdownConverter) cellMarkdown(cell *html.Node) string {
+	content := mc.convertChildren(cell)
+	content = strings.ReplaceAll(content, "\\\n", "\n")
+
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "<br>")
+}
+
+// escapeTableCell escapes pipes, which GFM requires even inside code spans in a table
+func escapeTableCell(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
+
+// cellAlignment reads a cell's alignment from its align attribute or text-align style
+func cellAlignment(cell *html.Node) string {
+	if align := strings.ToLower(getAttr(cell, "align")); align != "" {
+		return align
+	}
+
+	style := strings.ReplaceAll(strings.ToLower(getAttr(cell, "style")), " ", "")
+	for _, align := range []string{"left", "center", "right"} {
+		if strings.Contains(style, "text-align:"+align) {
+			return align
+		}
+	}
+	return ""
+}
+
+// isComplexTable reports whether a table has spans, code blocks or nested
+// tables that a pipe table cannot represent
+func isComplexTable(rows []*html.Node) bool {
+	for _, tr := range rows {
+		for _, cell := range rowCells(tr) {
+			if cellSpan(cell, "colspan") > 1 || cellSpan(cell, "rowspan") > 1 {
+				return true
+			}
+			if findChild(cell, atom.Pre) != nil || findChild(cell, atom.Table) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tableAsHTML renders a complex table as minimal HTML: only the structure and
+// spans are kept, and cell content is reduced to inline formatting and links
+func (mc *markdownConverter) tableAsHTML(rows []*html.Node) string {
+	var sb strings.Builder
+	sb.WriteString("<table>\n")
+	for _, tr := range rows {
+		cells := rowCells(tr)
+		if len(cells) == 0 {
+			continue
+		}
+
+		sb.WriteString("<tr>")
+		for _, cell := range cells {
+			tag := "td"
+			if cell.DataAtom == atom.Th {
+				tag = "th"
+			}
+
+			sb.WriteString("<" + tag)
+			for _, span := range []string{"colspan", "rowspan"} {
+				if n := cellSpan(cell, span); n > 1 {
+					sb.WriteString(" " + span + `="` + strconv.Itoa(n) + `"`)
+				}
+			}
+			if align := cellAlignment(cell); align != "" {
+				sb.WriteString(` align="` + html.EscapeString(align) + `"`)
+			}
+			sb.WriteString(">")
+
+			var content strings.Builder
+			mc.writeCellHTML(&content, cell)
+			sb.WriteString(strings.TrimSpace(content.String()))
+
+			sb.WriteString("</" + tag + ">")
+		}
+		sb.WriteString("</tr>\n")
+	}
+	sb.WriteString("</table>")
+	return sb.String()
+}
+
+// cellHTMLTags are the inline elements kept inside HTML fallback tables
+var cellHTMLTags = map[atom.Atom]bool{
+	atom.Code: true, atom.Strong: true, atom.B: true, atom.Em: true, atom.I: true,
+	atom.Kbd: true, atom.Sub: true, atom.Sup: true, atom.Del: true,
+}
+
+// writeCellHTML serializes a cell's content as HTML without blank lines,
+// which would otherwise end the HTML block in markdown
+func (mc *markdownConverter) writeCellHTML(sb *strings.Builder, n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch {
+		case c.Type == html.TextNode:
+			sb.WriteString(html.EscapeString(collapseWhitespace(c.Data)))
+		case c.Type != html.ElementNode || skippedElements[c.DataAtom] || isHidden(c):
+		case c.DataAtom == atom.Br:
+			sb.WriteString("<br>")
+		case c.DataAtom == atom.Pre:
+			// Keep indentation visible once the lines are joined with <br>
+			lines := strings.Split(html.EscapeString(codeText(c)), "\n")
+			for i, line := range lines {
+				indent := len(line) - len(strings.TrimLeft(line, " "))
+				lines[i] = strings.Repeat("&nbsp;", indent) + line[indent:]
+			}
+			sb.WriteString("<code>" + strings.Join(lines, "<br>") + "</code>")
+		case c.DataAtom == atom.Table:
+			// A nested table keeps its text; spans inside it cannot be expressed here
+			sb.WriteString(html.EscapeString(strings.Join(strings.Fields(textContent(c)), " ")))
+		case c.DataAtom == atom.A && getAttr(c, "href") != "" && !strings.HasPrefix(getAttr(c, "href"), "#"):
+			sb.WriteString(`<a href="` + html.EscapeString(strings.Trim(mc.resolve(getAttr(c, "href")), "<>")) + `">`)
+			mc.writeCellHTML(sb, c)
+			sb.WriteString("</a>")
+		case cellHTMLTags[c.DataAtom]:
+			sb.WriteString("<" + c.Data + ">")
+			mc.writeCellHTML(sb, c)
+			sb.WriteString("</" + c.Data + ">")
+		case isBlock(c):
+			// Paragraphs and list items inside a cell become line breaks
+			if sb.Len() > 0 {
+				sb.WriteString("<br>")
+			}
+			mc.writeCellHTML(sb, c)
+		default:
+			mc.writeCellHTML(sb, c)
+		}
+	}
+}
+
+// tableAsList renders a complex table as a list, one item per body row.
+// Spanned cells are repeated in every row and column they cover, so each
+// item stands on its own.
+func (mc *markdownConverter) tableAsList(rows []*html.Node) string {
+	grid := tableGrid(rows)
+	if len(grid) == 0 {
+		return ""
+	}
+
+	// Leading rows made only of header cells label the columns
+	headerRows := 0
+	for headerRows < len(grid) && isHeaderRow(grid[headerRows]) {
+		headerRows++
+	}
+	if headerRows == len(grid) {
+		headerRows = 0
+	}
+
+	rendered := make(map[*tableCell]string)
+	text := func(cell *tableCell) string {
+		if cell == nil {
+			return ""
+		}
+		if s, ok := rendered[cell]; ok {
+			return s
+		}
+		s := mc.convertChildren(cell.node)
+		rendered[cell] = s
+		return s
+	}
+
+	// Column labels are built from every header row, e.g. "Limits / Min"
+	labels := make([][]string, len(grid[0]))
+	for c := range labels {
+		for r := 0; r < headerRows; r++ {
+			if c < len(grid[r]) {
+				label := strings.Join(strings.Fields(text(grid[r][c])), " ")
+				if label != "" && (len(labels[c]) == 0 || labels[c][len(labels[c])-1] != label) {
+					labels[c] = append(labels[c], label)
+				}
+			}
+		}
+	}
+
+	var items []string
+	for _, row := range grid[headerRows:] {
+		var fields []string
+		for c := 0; c < len(row); c++ {
+			cell := row[c]
+			if cell == nil {
+				continue
+			}
+
+			// A colspan repeats the same cell across the row; list it once,
+			// under the label its columns share
+			label := labels[c]
+			for c+1 < len(row) && row[c+1] == cell {
+				c++
+				label = commonPrefix(label, labels[c])
+			}
+
+			value := text(cell)
+			if value == "" {
+				continue
+			}
+			if len(label) > 0 {
+				if strings.Contains(value, "\n") {
+					value = "**" + strings.Join(label, " / ") + "**:\n" + value
+				} else {
+					value = "**" + strings.Join(label, " / ") + "**: " + value
+				}
+			}
+			fields = append(fields, value)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		item := "- " + prefixLines(fields[0], "  ", "")[2:]
+		for _, field := range fields[1:] {
+			item += "\n  - " + prefixLines(field, "    ", "")[4:]
+		}
+		items = append(items, item)
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// commonPrefix returns the leading labels two label paths share
+func commonPrefix(a, b []string) []string {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return a[:n]
+}
+
+// tableGrid lays a table's cells out on a grid, expanding colspan and rowspan
+func tableGrid(rows []*html.Node) [][]*tableCell {
+	var grid [][]*tableCell
+	ensure := func(r, c int) {
+		for len(grid) <= r {
+			grid = append(grid, nil)
+		}
+		for len(grid[r]) <= c {
+			grid[r] = append(grid[r], nil)
+		}
+	}
+
+	for r, tr := range rows {
+		ensure(r, 0)
+		c := 0
+		for _, node := range rowCells(tr) {
+			// Skip slots already filled by a rowspan from above
+			for c < len(grid[r]) && grid[r][c] != nil {
+				c++
+			}
+
+			cell := &tableCell{
+				node:    node,
+				header:  node.DataAtom == atom.Th || node.Parent != nil && node.Parent.Parent != nil && node.Parent.Parent.DataAtom == atom.Thead,
+				colspan: cellSpan(node, "colspan"),
+				rowspan: cellSpan(node, "rowspan"),
+			}
+			for dr := 0; dr < cell.rowspan && r+dr < len(rows); dr++ {
+				for dc := 0; dc < cell.colspan; dc++ {
+					ensure(r+dr, c+dc)
+					grid[r+dr][c+dc] = cell
+				}
+			}
+			c += cell.colspan
+		}
+	}
+
+	// Pad ragged rows so every row has every column
+	columns := 0
+	for _, row := range grid {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+	for r := range grid {
+		if len(grid[r]) > 0 {
+			ensure(r, columns-1)
+		}
+	}
+	return grid
+}
+
+// isHeaderRow reports whether every cell in a grid row is a header cell
+func isHeaderRow(row []*tableCell) bool {
+	found := false
+	for _, cell := range row {
+		if cell == nil {
+			continue
+		}
+		if !cell.header {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// cellSpan reads colspan or rowspan, treating missing or invalid values as 1
+func cellSpan(cell *html.Node, attr string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(getAttr(cell, attr)))
+	if err != nil || n < 1 {
+		return 1
+	}
+	if n > 1000 {
+		return 1000 // HTML caps colspan at 1000 and rowspan at 65534
+	}
+	return n
+}
+
+// tableRows returns a table's rows in document order, skipping nested tables
+func tableRows(table *html.Node) []*html.Node {
+	var rows []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Tr:
+				rows = append(rows, c)
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(c)
+			}
+		}
+	}
+	walk(table)
+	return rows
+}
+
+// rowCells returns the th and td cells of a row
+func rowCells(tr *html.Node) []*html.Node {
+	var cells []*html.Node
+	for c := tr.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (c.DataAtom == atom.Td || c.DataAtom == atom.Th) {
+			cells = append(cells, c)
+		}
+	}
+	return cells
+}
+
+// collapseWhitespace replaces whitespace runs with single spaces, keeping a
+// space at either end if there was whitespace there
+func collapseWhitespace(text string) string {
+	collapsed := strings.Join(strings.Fields(text), " ")
+	if collapsed == "" {
+		if text != "" {
+			return " "
+		}
+		return ""
+	}
+	if startsWithSpace(text) {
+		collapsed = " " + collapsed
+	}
+	if endsWithSpace(text) {
+		collapsed += " "
+	}
+	return collapsed
+}
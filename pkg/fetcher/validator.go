@@ -13,7 +13,11 @@ func ValidateConfig(config *Config) error {
 	if err := validateURL(config.BaseURL); err != nil {
 		return fmt.Errorf("invalid URL: %w", err)
 	}
-	if err := validateOutputPath(config.OutputPath); err != nil {
+	if config.OutputDir != "" {
+		if err := validateLocalPath(config.OutputDir); err != nil {
+			return fmt.Errorf("invalid output directory: %w", err)
+		}
+	} else if err := validateOutputPath(config.OutputPath); err != nil {
 		return fmt.Errorf("invalid output path: %w", err)
 	}
 	if config.MaxDepth > 10 {
@@ -22,6 +26,66 @@ func ValidateConfig(config *Config) error {
 	if config.Workers > 20 {
 		return fmt.Errorf("too many concurrent workers (maximum allowed: 20)")
 	}
+	if config.MaxPages < 0 {
+		return fmt.Errorf("max pages cannot be negative")
+	}
+	if config.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	if config.SitemapURL != "" {
+		if err := validateURL(config.SitemapURL); err != nil {
+			return fmt.Errorf("invalid sitemap URL: %w", err)
+		}
+	}
+	if config.Format != "" {
+		if err := checkOutputFormat(config); err != nil {
+			return err
+		}
+	}
+	if config.ChunkTokens < 0 {
+		return fmt.Errorf("chunk size cannot be negative")
+	}
+	if config.ChunkTokens > 0 && config.ChunkOverlap >= config.ChunkTokens {
+		return fmt.Errorf("chunk overlap must be smaller than the chunk size")
+	}
+	if err := checkLLMTxtOptions(config); err != nil {
+		return err
+	}
+	if config.TablePolicy != "" && config.TablePolicy != TablePolicyHTML && config.TablePolicy != TablePolicyList {
+		return fmt.Errorf("invalid table policy %q (use %q or %q)", config.TablePolicy, TablePolicyHTML, TablePolicyList)
+	}
+	if config.SiteConfigPath != "" {
+		if _, err := LoadSiteConfig(config.SiteConfigPath); err != nil {
+			return err
+		}
+	}
+	if _, err := compileSiteRules(config.SiteRules); err != nil {
+		return fmt.Errorf("invalid site rules: %w", err)
+	}
+	if config.ReportPath != "" {
+		if err := validateJSONPath(config.ReportPath); err != nil {
+			return fmt.Errorf("invalid report path: %w", err)
+		}
+	}
+	if config.StatePath != "" {
+		if err := validateJSONPath(config.StatePath); err != nil {
+			return fmt.Errorf("invalid state path: %w", err)
+		}
+	}
+	if config.CacheDir != "" {
+		if err := validateLocalPath(config.CacheDir); err != nil {
+			return fmt.Errorf("invalid cache directory: %w", err)
+		}
+	}
+	if config.Offline && config.CacheDir == "" {
+		return fmt.Errorf("offline mode needs a cache directory")
+	}
+	if _, err := compileScopePatterns(config.IncludePatterns); err != nil {
+		return err
+	}
+	if _, err := compileScopePatterns(config.ExcludePatterns); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -59,6 +123,40 @@ func validateURL(urlStr string) error {
 
 // validateOutputPath ensures the output path is safe
 func validateOutputPath(path string) error {
+	if err := validateLocalPath(path); err != nil {
+		return err
+	}
+
+	// Check file extension - only allow safe extensions
+	allowedExtensions := []string{".md", ".txt", ".llm.txt", ".jsonl"}
+	ext := filepath.Ext(path)
+	isAllowed := false
+	for _, allowed := range allowedExtensions {
+		if ext == allowed {
+			isAllowed = true
+			break
+		}
+	}
+	if !isAllowed {
+		return fmt.Errorf("only .md, .txt, .llm.txt and .jsonl file extensions are allowed")
+	}
+
+	return nil
+}
+
+// validateJSONPath ensures a JSON file path, such as the extraction report's, is safe
+func validateJSONPath(path string) error {
+	if err := validateLocalPath(path); err != nil {
+		return err
+	}
+	if filepath.Ext(path) != ".json" {
+		return fmt.Errorf("only the .json file extension is allowed")
+	}
+	return nil
+}
+
+// validateLocalPath ensures a path stays within the current working directory
+func validateLocalPath(path string) error {
 	// Don't allow absolute paths that start with /
 	if strings.HasPrefix(path, "/") {
 		return fmt.Errorf("absolute paths are not allowed")
@@ -91,19 +189,5 @@ func validateOutputPath(path string) error {
 		return fmt.Errorf("output path must be within the current working directory")
 	}
 
-	// Check file extension - only allow safe extensions
-	allowedExtensions := []string{".md", ".txt", ".llm.txt"}
-	ext := filepath.Ext(path)
-	isAllowed := false
-	for _, allowed := range allowedExtensions {
-		if ext == allowed {
-			isAllowed = true
-			break
-		}
-	}
-	if !isAllowed {
-		return fmt.Errorf("only .md, .txt, and .llm.txt file extensions are allowed")
-	}
-
 	return nil
 }
\ No newline at end of file